@@ -2,9 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pkg/profile"
@@ -15,6 +20,8 @@ import (
 
 	"github.com/codingconcepts/dgs/pkg/commands"
 	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/output"
+	"github.com/codingconcepts/dgs/pkg/random"
 )
 
 var (
@@ -29,10 +36,32 @@ var (
 	cpuProfile string
 
 	// Gen data flags.
-	config     string
-	batch      int
-	workers    int
-	insertMode string
+	config             string
+	batch              int
+	workers            int
+	writers            int
+	queueSize          int
+	retries            int
+	backoff            time.Duration
+	maxBackoff         time.Duration
+	topUps             int
+	stmtTimeout        time.Duration
+	checkpoint         string
+	checkpointInterval time.Duration
+	resume             bool
+	seed               int64
+	appendRows         bool
+	appendRefs         int
+	insertMode         string
+	outputMode         string
+	outputDir          string
+	fileRows           int
+	embeds             []string
+	singleFile         bool
+	serve              bool
+
+	// Serve flags (shared with gen data).
+	addr string
 
 	// Gen config flags.
 	schema    string
@@ -54,7 +83,6 @@ func main() {
 	genCmd.PersistentFlags().StringVar(&url, "url", "", "connection string")
 	genCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enhable debug logging")
 	genCmd.PersistentFlags().StringVar(&cpuProfile, "cpu-profile", "", "run cpu profiling (output to cpuprof)")
-	genCmd.MarkPersistentFlagRequired("url")
 
 	genDataCmd := &cobra.Command{
 		Use:   "data",
@@ -64,8 +92,28 @@ func main() {
 
 	genDataCmd.Flags().StringVar(&config, "config", "", "absolute or relative path to the config file")
 	genDataCmd.Flags().IntVar(&batch, "batch", 1000, "query and insert batch size")
-	genDataCmd.Flags().IntVar(&workers, "workers", 4, "number of workers to run concurrently")
-	genDataCmd.Flags().StringVar(&insertMode, "insert-mode", "upsert", "type of insert to run [insert | upsert]")
+	genDataCmd.Flags().IntVar(&workers, "workers", 4, "number of workers generating each table's rows concurrently")
+	genDataCmd.Flags().IntVar(&writers, "writers", 0, "number of writers writing each table's rows concurrently (defaults to --workers)")
+	genDataCmd.Flags().IntVar(&queueSize, "queue-size", 8, "number of generated batches that can wait to be written")
+	genDataCmd.Flags().IntVar(&retries, "retries", 5, "maximum number of attempts for each batch that fails with a retryable error")
+	genDataCmd.Flags().DurationVar(&backoff, "retry-backoff", time.Millisecond*100, "delay before the first retry, doubling for each subsequent retry")
+	genDataCmd.Flags().DurationVar(&maxBackoff, "retry-max-backoff", time.Second*10, "maximum delay between retries")
+	genDataCmd.Flags().IntVar(&topUps, "top-ups", 10, "maximum number of times a batch is topped up to replace rows skipped by --insert-mode conflict")
+	genDataCmd.Flags().DurationVar(&stmtTimeout, "statement-timeout", time.Second*30, "maximum duration of each insert statement, or 0 for no timeout (database only)")
+	genDataCmd.Flags().StringVar(&checkpoint, "checkpoint", "", "file to record progress in, allowing an interrupted run to be resumed")
+	genDataCmd.Flags().DurationVar(&checkpointInterval, "checkpoint-interval", time.Second*10, "minimum time between checkpoints (0 records progress after every batch)")
+	genDataCmd.Flags().BoolVar(&resume, "resume", false, "continue from the progress recorded in --checkpoint")
+	genDataCmd.Flags().Int64Var(&seed, "seed", 0, "seed for generating random values (random if 0, or the checkpoint's seed when resuming)")
+	genDataCmd.Flags().BoolVar(&appendRows, "append", false, "add rows to tables that already contain data, continuing inc columns and referencing existing rows (database only)")
+	genDataCmd.Flags().IntVar(&appendRefs, "append-refs", 100000, "maximum number of existing values to load for each referenced column when appending")
+	genDataCmd.Flags().StringVar(&insertMode, "insert-mode", "upsert", "type of insert to run [insert | conflict | upsert]")
+	genDataCmd.Flags().StringVar(&outputMode, "output", "database", "where to write generated data [database | csv | parquet | jsonl | sql]")
+	genDataCmd.Flags().StringVar(&outputDir, "dir", ".", "directory to write files into (file outputs only)")
+	genDataCmd.Flags().IntVar(&fileRows, "file-rows", 0, "maximum rows per file, partitioning tables into multiple files (parquet only)")
+	genDataCmd.Flags().StringSliceVar(&embeds, "embed", nil, "nest a child table's rows inside the parent rows they reference as CHILD_TABLE.REF_COLUMN (jsonl only)")
+	genDataCmd.Flags().BoolVar(&singleFile, "single-file", false, "write every table into a single ordered script, rather than one file per table (sql only)")
+	genDataCmd.Flags().BoolVar(&serve, "serve", false, "serve the generated files over HTTP once generation completes (csv only)")
+	genDataCmd.Flags().StringVar(&addr, "addr", "localhost:3000", "address to serve files on")
 	genDataCmd.MarkFlagRequired("config")
 
 	genConfigCmd := &cobra.Command{
@@ -86,7 +134,18 @@ func main() {
 		Run:   showVersion,
 	}
 
-	rootCmd.AddCommand(genCmd, versionCmd)
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve generated CSV files over HTTP for IMPORT INTO",
+		Run:   serveFiles,
+	}
+
+	serveCmd.Flags().StringVar(&config, "config", "", "absolute or relative path to the config file the files were generated from")
+	serveCmd.Flags().StringVar(&outputDir, "dir", ".", "directory to serve files from")
+	serveCmd.Flags().StringVar(&addr, "addr", "localhost:3000", "address to serve files on")
+	serveCmd.MarkFlagRequired("config")
+
+	rootCmd.AddCommand(genCmd, serveCmd, versionCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatalf("error running dgs: %v", err)
@@ -107,6 +166,171 @@ func genData(cmd *cobra.Command, args []string) {
 		defer profile.Start(profile.ProfilePath(".")).Stop()
 	}
 
+	if serve && model.ParseOutputMode(outputMode) != model.OutputModeCSV {
+		logger.Fatal().Msg("--serve is only supported for csv output")
+	}
+
+	if appendRows && model.ParseOutputMode(outputMode) != model.OutputModeDatabase {
+		logger.Fatal().Msg("--append is only supported for database output")
+	}
+
+	switch {
+	case batch < 1:
+		logger.Fatal().Msg("--batch must be at least 1")
+	case workers < 1:
+		logger.Fatal().Msg("--workers must be at least 1")
+	case writers < 0:
+		logger.Fatal().Msg("--writers must be at least 1, or 0 to default to --workers")
+	case queueSize < 0:
+		logger.Fatal().Msg("--queue-size can't be negative")
+	case topUps < 0:
+		logger.Fatal().Msg("--top-ups can't be negative")
+	}
+
+	c := mustParseConfig()
+
+	if writers == 0 {
+		writers = workers
+	}
+
+	// Stop generating on SIGINT or SIGTERM. Once cancelled, the default
+	// signal behaviour is restored, so a second signal exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	writer, closeWriter := mustCreateWriter(c)
+	defer closeWriter()
+
+	if appendRows || commands.HasRefQueries(c) {
+		mustLoadExistingData(ctx, c)
+	}
+
+	retryPolicy := commands.RetryPolicy{
+		Attempts:   retries,
+		Backoff:    backoff,
+		MaxBackoff: maxBackoff,
+		TopUps:     topUps,
+	}
+
+	checkpointPolicy := mustCreateCheckpointPolicy(cmd)
+
+	if model.ParseOutputMode(outputMode) == model.OutputModeDatabase {
+		if err := commands.CheckInsertMode(c, model.ParseInsertMode(insertMode)); err != nil {
+			logger.Fatal().Msgf("error checking insert mode: %v", err)
+		}
+	}
+
+	if (appendRows || resume) && model.ParseOutputMode(outputMode) == model.OutputModeDatabase {
+		if err := commands.CheckContinuation(c, checkpointPolicy.Resume, model.ParseInsertMode(insertMode)); err != nil {
+			logger.Fatal().Msgf("error continuing from existing rows: %v", err)
+		}
+	}
+
+	g := commands.NewDataGenerator(writer, logger, c, workers, writers, batch, queueSize, retryPolicy, checkpointPolicy)
+
+	logger.Debug().Msg("generating data")
+	genErr := g.Generate(ctx)
+
+	// Close the writer regardless, so that anything written before an error
+	// or cancellation is flushed.
+	if err := writer.Close(); err != nil {
+		logger.Fatal().Msgf("error closing output: %v", err)
+	}
+
+	if errors.Is(genErr, context.Canceled) {
+		logger.Fatal().Msg("generation cancelled")
+	}
+	if genErr != nil {
+		logger.Fatal().Msgf("error generating data: %v", genErr)
+	}
+
+	logger.Info().Msg("done")
+
+	if serve {
+		if err := commands.Serve(addr, outputDir, c.Tables, logger); err != nil {
+			logger.Fatal().Msgf("error serving files: %v", err)
+		}
+	}
+}
+
+// mustLoadExistingData reads the data that generation depends on from the
+// database. When appending, this continues the config's inc columns and
+// loads the values of referenced columns from the tables being appended
+// to. The values of ref_query columns are always loaded.
+func mustLoadExistingData(ctx context.Context, c model.Config) {
+	if url == "" {
+		logger.Fatal().Msg("--url is required to read existing data for --append or ref_query columns")
+	}
+
+	db := mustConnect(url, model.Config{})
+	defer db.Close()
+
+	if appendRows {
+		if err := commands.PrepareAppend(ctx, db, c, appendRefs, logger); err != nil {
+			logger.Fatal().Msgf("error preparing to append: %v", err)
+		}
+	}
+
+	if err := commands.LoadRefQueries(ctx, db, c, logger); err != nil {
+		logger.Fatal().Msgf("error loading ref_query values: %v", err)
+	}
+}
+
+// mustCreateCheckpointPolicy loads the checkpoint to resume from (if
+// resuming) and seeds random value generation.
+func mustCreateCheckpointPolicy(cmd *cobra.Command) commands.CheckpointPolicy {
+	policy := commands.CheckpointPolicy{
+		Path:     checkpoint,
+		Interval: checkpointInterval,
+	}
+
+	// Offset the seed by the rows already written, so that a resumed run
+	// doesn't repeat the values generated at the start of the original.
+	var offset int64
+
+	if resume {
+		if checkpoint == "" {
+			logger.Fatal().Msg("--checkpoint is required to resume")
+		}
+
+		cp, err := commands.LoadCheckpoint(checkpoint)
+		if err != nil {
+			logger.Fatal().Msgf("error loading checkpoint: %v", err)
+		}
+
+		if !cmd.Flags().Changed("seed") {
+			seed = cp.Seed
+		}
+
+		offset = int64(cp.TotalRows())
+		policy.Resume = &cp
+	}
+
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	logger.Info().Int64("seed", seed).Msg("seeding")
+	random.Seed(seed + offset)
+
+	policy.Seed = seed
+	return policy
+}
+
+func serveFiles(cmd *cobra.Command, args []string) {
+	c := mustParseConfig()
+
+	if err := commands.Serve(addr, outputDir, c.Tables, logger); err != nil {
+		logger.Fatal().Msgf("error serving files: %v", err)
+	}
+}
+
+func mustParseConfig() model.Config {
 	logger.Debug().Msgf("reading file: %s", config)
 	file, err := os.ReadFile(config)
 	if err != nil {
@@ -119,22 +343,11 @@ func genData(cmd *cobra.Command, args []string) {
 		logger.Fatal().Msgf("error parsing config file: %v", err)
 	}
 
-	db := mustConnect(url)
-	defer db.Close()
-
-	parsedInsertMode := model.ParseInsertMode(insertMode)
-	if parsedInsertMode == model.InsertModeInvalid {
-		logger.Fatal().Msgf("%s is not a valid insert-mode", insertMode)
+	if err = model.LoadColumnFiles(c, filepath.Dir(config)); err != nil {
+		logger.Fatal().Msgf("error loading column files: %v", err)
 	}
 
-	g := commands.NewDataGenerator(db, logger, c, workers, batch, parsedInsertMode)
-
-	logger.Debug().Msg("generating data")
-	if err = g.Generate(); err != nil {
-		logger.Fatal().Msgf("error generating data: %v", err)
-	}
-
-	logger.Info().Msg("done")
+	return c
 }
 
 func genConfig(cmd *cobra.Command, args []string) {
@@ -142,7 +355,11 @@ func genConfig(cmd *cobra.Command, args []string) {
 		defer profile.Start(profile.ProfilePath(".")).Stop()
 	}
 
-	db := mustConnect(url)
+	if url == "" {
+		logger.Fatal().Msg("--url is required to generate config")
+	}
+
+	db := mustConnect(url, model.Config{})
 	defer db.Close()
 
 	config, err := commands.GenerateConfig(db, schema, rowCounts)
@@ -155,12 +372,79 @@ func genConfig(cmd *cobra.Command, args []string) {
 	}
 }
 
-func mustConnect(url string) *pgxpool.Pool {
+// mustCreateWriter returns the output writer for the selected output mode,
+// along with a function that releases any resources the writer depends on.
+func mustCreateWriter(c model.Config) (output.Writer, func()) {
+	parsedInsertMode := model.ParseInsertMode(insertMode)
+	if parsedInsertMode == model.InsertModeInvalid {
+		logger.Fatal().Msgf("%s is not a valid insert-mode", insertMode)
+	}
+
+	switch model.ParseOutputMode(outputMode) {
+	case model.OutputModeDatabase:
+		if url == "" {
+			logger.Fatal().Msg("--url is required when writing to a database")
+		}
+
+		db := mustConnect(url, c)
+		return output.NewDatabaseWriter(db, logger, parsedInsertMode, stmtTimeout), db.Close
+
+	case model.OutputModeCSV:
+		writer, err := output.NewCSVWriter(outputDir)
+		if err != nil {
+			logger.Fatal().Msgf("error creating csv writer: %v", err)
+		}
+		return writer, func() {}
+
+	case model.OutputModeParquet:
+		writer, err := output.NewParquetWriter(outputDir, c.Tables, fileRows)
+		if err != nil {
+			logger.Fatal().Msgf("error creating parquet writer: %v", err)
+		}
+		return writer, func() {}
+
+	case model.OutputModeJSONL:
+		parsedEmbeds := make([]output.Embed, len(embeds))
+		for i, e := range embeds {
+			var err error
+			if parsedEmbeds[i], err = output.ParseEmbed(e, c.Tables); err != nil {
+				logger.Fatal().Msgf("error parsing embed: %v", err)
+			}
+		}
+
+		writer, err := output.NewJSONLWriter(outputDir, parsedEmbeds)
+		if err != nil {
+			logger.Fatal().Msgf("error creating jsonl writer: %v", err)
+		}
+		return writer, func() {}
+
+	case model.OutputModeSQL:
+		writer, err := output.NewSQLWriter(outputDir, singleFile, parsedInsertMode)
+		if err != nil {
+			logger.Fatal().Msgf("error creating sql writer: %v", err)
+		}
+		return writer, func() {}
+
+	default:
+		logger.Fatal().Msgf("%s is not a valid output", outputMode)
+		return nil, nil
+	}
+}
+
+func mustConnect(url string, c model.Config) *pgxpool.Pool {
 	cfg, err := pgxpool.ParseConfig(url)
 	if err != nil {
 		log.Fatalf("error parsing connection string: %v", err)
 	}
-	cfg.MaxConns = int32(workers)
+
+	// Allow enough connections for every table's writers, as tables that
+	// don't reference one another are written concurrently. Connections are
+	// only opened when needed, so tables that can't run at the same time
+	// don't hold connections that they aren't using.
+	totalWriters := lo.SumBy(c.Tables, func(t model.Table) int {
+		return lo.Ternary(t.Writers > 0, t.Writers, writers)
+	})
+	cfg.MaxConns = int32(max(totalWriters, 1))
 
 	db, err := pgxpool.NewWithConfig(context.Background(), cfg)
 	if err != nil {
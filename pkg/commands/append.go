@@ -0,0 +1,239 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/query"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+)
+
+// PrepareAppend readies a config for adding rows to tables that already
+// contain data. Each inc column is moved past the largest number already
+// in its column (or, for per sequences, the largest for each value of the
+// per column), cumulative columns and series continue from the existing
+// rows, and up to refLimit existing values of each referenced column are
+// loaded, so that new rows can reference existing ones.
+func PrepareAppend(ctx context.Context, db *pgxpool.Pool, config model.Config, refLimit int, logger zerolog.Logger) error {
+	for i := range config.Tables {
+		table := &config.Tables[i]
+
+		for _, c := range table.Columns {
+			if c.Mode != model.ColumnTypeInc {
+				continue
+			}
+
+			// Wrapping sequences repeat their numbers by design, so start
+			// from the beginning again.
+			props := c.NextID.Props()
+			if props.Max != 0 {
+				logger.Info().
+					Str("table", table.Name).
+					Str("column", c.Name).
+					Msg("not continuing wrapping sequence")
+				continue
+			}
+
+			prefix, suffix, err := c.NextID.Affixes()
+			if err != nil {
+				return fmt.Errorf("continuing sequence %s.%s: %w", table.Name, c.Name, err)
+			}
+
+			if props.Per != "" {
+				if err := continuePerSequence(ctx, db, table.Name, c, prefix, suffix); err != nil {
+					return fmt.Errorf("continuing sequences of %s.%s: %w", table.Name, c.Name, err)
+				}
+
+				logger.Info().
+					Str("table", table.Name).
+					Str("column", c.Name).
+					Int("sequences", len(c.NextID.Positions())).
+					Msg("continuing sequences")
+				continue
+			}
+
+			var max *int64
+			if err := db.QueryRow(ctx, query.BuildMax(table.Name, c.Name, prefix, suffix)).Scan(&max); err != nil {
+				return fmt.Errorf("fetching max of %s.%s: %w", table.Name, c.Name, err)
+			}
+
+			if max != nil {
+				c.NextID.SkipPast(*max)
+			}
+
+			logger.Info().
+				Str("table", table.Name).
+				Str("column", c.Name).
+				Int64("start", c.NextID.Position()).
+				Msg("continuing sequence")
+		}
+
+		for _, c := range table.Columns {
+			if c.Mode != model.ColumnTypeCumulative {
+				continue
+			}
+
+			if err := continueTotals(ctx, db, table.Name, c); err != nil {
+				return fmt.Errorf("continuing totals of %s.%s: %w", table.Name, c.Name, err)
+			}
+
+			logger.Info().
+				Str("table", table.Name).
+				Str("column", c.Name).
+				Int("totals", len(c.Total.Totals())).
+				Msg("continuing totals")
+		}
+
+		if table.Series != nil {
+			if err := continueSeries(ctx, db, table.Name, table.Series); err != nil {
+				return fmt.Errorf("continuing series of %s: %w", table.Name, err)
+			}
+
+			logger.Info().
+				Str("table", table.Name).
+				Int("series", len(table.Series.Existing)).
+				Msg("continuing series")
+		}
+
+		for _, column := range lo.Uniq(table.RefColumns) {
+			values, err := fetchValues(ctx, db, table.Name, column, refLimit)
+			if err != nil {
+				return fmt.Errorf("fetching values of %s.%s: %w", table.Name, column, err)
+			}
+
+			if table.Existing == nil {
+				table.Existing = map[string][]any{}
+			}
+			table.Existing[column] = values
+
+			logger.Info().
+				Str("table", table.Name).
+				Str("column", column).
+				Int("values", len(values)).
+				Msg("loaded existing values")
+		}
+	}
+
+	return nil
+}
+
+// continuePerSequence moves the sequence of each of a per sequence's
+// existing keys past the largest number already numbered by it.
+func continuePerSequence(ctx context.Context, db *pgxpool.Pool, table string, c model.Column, prefix, suffix string) error {
+	rows, err := db.Query(ctx, query.BuildMaxPer(table, c.Name, c.NextID.Props().Per, prefix, suffix))
+	if err != nil {
+		return fmt.Errorf("querying max per %s: %w", c.NextID.Props().Per, err)
+	}
+
+	var key string
+	var max *int64
+	_, err = pgx.ForEachRow(rows, []any{&key, &max}, func() error {
+		if max != nil {
+			c.NextID.SkipPastFor(key, *max)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scanning max per %s: %w", c.NextID.Props().Per, err)
+	}
+
+	return nil
+}
+
+// continueTotals adds the sum of the values that existing rows have
+// already totalled to each of a cumulative column's totals.
+func continueTotals(ctx context.Context, db *pgxpool.Pool, table string, c model.Column) error {
+	rows, err := db.Query(ctx, query.BuildSumPer(table, c.Cumulative, c.Total.Props().Per))
+	if err != nil {
+		return fmt.Errorf("querying sums: %w", err)
+	}
+
+	var key string
+	var sum *string
+	_, err = pgx.ForEachRow(rows, []any{&key, &sum}, func() error {
+		if sum == nil {
+			return nil
+		}
+
+		v, err := parseSum(*sum)
+		if err != nil {
+			return err
+		}
+
+		if _, err = c.Total.Add(key, v); err != nil {
+			return err
+		}
+		return c.Total.Commit(key, v)
+	})
+	if err != nil {
+		return fmt.Errorf("scanning sums: %w", err)
+	}
+
+	return nil
+}
+
+// parseSum returns a sum as an integer if it's a whole number, so that
+// totals of integers remain integers, or a float otherwise.
+func parseSum(sum string) (any, error) {
+	if n, err := strconv.ParseInt(sum, 10, 64); err == nil {
+		return n, nil
+	}
+
+	f, err := strconv.ParseFloat(sum, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing sum %q: %w", sum, err)
+	}
+	return f, nil
+}
+
+// continueSeries continues each series that existing rows have started
+// from its last reading.
+func continueSeries(ctx context.Context, db *pgxpool.Pool, table string, s *model.Series) error {
+	values := lo.Map(s.Values, func(v model.SeriesValue, _ int) string { return v.Column })
+
+	rows, err := db.Query(ctx, query.BuildLastReadings(table, s.Per, s.Timestamp, values))
+	if err != nil {
+		return fmt.Errorf("querying last readings: %w", err)
+	}
+
+	var key string
+	var last time.Time
+	readings := make([]*float64, len(values))
+
+	dest := append([]any{&key, &last}, lo.Map(readings, func(_ *float64, i int) any { return &readings[i] })...)
+
+	s.Existing = map[string]model.SeriesPosition{}
+	_, err = pgx.ForEachRow(rows, dest, func() error {
+		s.Existing[key] = seriesPosition(*s, last, readings)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scanning last readings: %w", err)
+	}
+
+	return nil
+}
+
+func fetchValues(ctx context.Context, db *pgxpool.Pool, table, column string, limit int) ([]any, error) {
+	rows, err := db.Query(ctx, query.BuildSelectValues(table, column), limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying values: %w", err)
+	}
+
+	values, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (any, error) {
+		var v string
+		err := row.Scan(&v)
+		return v, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning values: %w", err)
+	}
+
+	return values, nil
+}
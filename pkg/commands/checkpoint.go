@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+)
+
+// CheckpointPolicy determines where, and how often, a generator records
+// its progress, and the progress to resume from.
+type CheckpointPolicy struct {
+	// Path is the file that checkpoints are written to. Checkpoints are
+	// disabled if empty.
+	Path string
+
+	// Interval is the minimum time between checkpoints. A final checkpoint
+	// is always written once generation stops.
+	Interval time.Duration
+
+	// Seed is the seed used to generate random values, which is recorded
+	// so that resumed runs can continue with it.
+	Seed int64
+
+	// Resume is the checkpoint to continue from, or nil to start afresh.
+	Resume *Checkpoint
+}
+
+// Checkpoint records the progress of a run, so that it can be resumed.
+type Checkpoint struct {
+	Seed   int64                      `json:"seed"`
+	Tables map[string]TableCheckpoint `json:"tables"`
+}
+
+// TableCheckpoint records the progress of a single table.
+type TableCheckpoint struct {
+	// Rows is the number of rows that have been written.
+	Rows int `json:"rows"`
+
+	// Sequences holds the next value of each inc column.
+	Sequences map[string]int64 `json:"sequences,omitempty"`
+
+	// PerSequences holds the next value of each key's sequence, of each inc
+	// column with a sequence per another column, while the table has rows
+	// left to generate.
+	PerSequences map[string]map[string]int64 `json:"per_sequences,omitempty"`
+
+	// Totals holds the running total of each key, of each cumulative
+	// column, while the table has rows left to generate.
+	Totals map[string]map[string]model.Total `json:"totals,omitempty"`
+
+	// Series holds the position of each of a series table's series, keyed
+	// by their per column value, while the table has rows left to generate.
+	Series map[string]model.SeriesPosition `json:"series,omitempty"`
+
+	// Refs holds the values of the table's referenced columns that rows
+	// in other tables can reference.
+	Refs map[string][]any `json:"refs,omitempty"`
+}
+
+// TotalRows returns the number of rows written across all tables.
+func (c Checkpoint) TotalRows() int {
+	var total int
+	for _, t := range c.Tables {
+		total += t.Rows
+	}
+	return total
+}
+
+// LoadCheckpoint reads a checkpoint from a file.
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	// Decode numbers without loss of precision, so that large integer keys
+	// are referenced exactly.
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var c Checkpoint
+	if err = dec.Decode(&c); err != nil {
+		return Checkpoint{}, fmt.Errorf("parsing checkpoint: %w", err)
+	}
+
+	for name, t := range c.Tables {
+		for ref, values := range t.Refs {
+			for i, v := range values {
+				values[i] = fromJSONNumber(v)
+			}
+			t.Refs[ref] = values
+		}
+		c.Tables[name] = t
+	}
+
+	return c, nil
+}
+
+// Save writes a checkpoint to a file. The checkpoint is written to a
+// temporary file that then replaces the original, so that a crash while
+// saving never leaves a partially written checkpoint behind.
+func (c Checkpoint) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling checkpoint: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing checkpoint: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing checkpoint: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing checkpoint: %w", err)
+	}
+
+	return nil
+}
+
+// fromJSONNumber converts a decoded JSON number into an int64 if it's a
+// whole number, or a float64 otherwise.
+func fromJSONNumber(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+
+	return n.String()
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint_SaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	c := Checkpoint{
+		Seed: 42,
+		Tables: map[string]TableCheckpoint{
+			"member": {
+				Rows:      1000,
+				Sequences: map[string]int64{"id": 1001},
+				Refs: map[string][]any{
+					"member.id":    {int64(1), int64(9007199254740993)},
+					"member.email": {"a@b.com", nil},
+				},
+			},
+			"purchase": {
+				Rows: 10,
+				Refs: map[string][]any{
+					"purchase.amount": {1.5},
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, c.Save(path))
+
+	// Saving again replaces the checkpoint without leaving files behind.
+	assert.NoError(t, c.Save(path))
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	act, err := LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Equal(t, c, act)
+	assert.Equal(t, 1010, act.TotalRows())
+}
+
+func TestLoadCheckpoint_Missing(t *testing.T) {
+	_, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
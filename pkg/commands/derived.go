@@ -0,0 +1,154 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/samber/lo"
+)
+
+// deriveValues sets the values of a row's derived columns from the values
+// of the columns that they're derived from. uuid_v5 columns are NULL if
+// the column they're derived from is NULL.
+func deriveValues(table model.Table, row []any) error {
+	for i, c := range table.Columns {
+		if c.Mode != model.ColumnTypeUUIDv5 {
+			continue
+		}
+
+		_, source, ok := lo.FindIndexOf(table.Columns, func(s model.Column) bool { return s.Name == c.UUIDv5 })
+		if !ok {
+			return fmt.Errorf("missing column %q to derive %q from", c.UUIDv5, c.Name)
+		}
+
+		if row[source] == nil {
+			row[i] = nil
+			continue
+		}
+
+		namespace, err := c.UUIDv5Namespace()
+		if err != nil {
+			return fmt.Errorf("deriving %q: %w", c.Name, err)
+		}
+
+		row[i] = random.UUIDv5(namespace, model.FormatValue(row[source]))
+	}
+
+	return nil
+}
+
+// deriveSequences numbers a row from each of its inc columns with a
+// sequence per another column. As rows only take one number from each
+// sequence, this is done once the row's other values are final.
+func deriveSequences(table model.Table, row []any) error {
+	for i, c := range table.Columns {
+		if c.Mode != model.ColumnTypeInc || c.NextID.Props().Per == "" {
+			continue
+		}
+
+		_, source, ok := lo.FindIndexOf(table.Columns, func(s model.Column) bool { return s.Name == c.NextID.Props().Per })
+		if !ok {
+			return fmt.Errorf("missing column %q to number %q by", c.NextID.Props().Per, c.Name)
+		}
+
+		row[i] = c.NextID.Value(c.NextID.NextFor(model.FormatValue(row[source])))
+	}
+
+	return nil
+}
+
+// deriveTotals adds a row to the running total of each of its cumulative
+// columns. As with per sequences, this is done once the row's other values
+// are final.
+func deriveTotals(table model.Table, row []any) error {
+	for i, c := range table.Columns {
+		if c.Mode != model.ColumnTypeCumulative {
+			continue
+		}
+
+		if err := deriveTotal(table, c, row, i); err != nil {
+			return fmt.Errorf("totalling %q: %w", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// CheckInsertMode returns an error if the conflict insert mode would
+// leave a table's derived values wrong. Rows that it skips have already
+// been added to their running totals, so cumulative columns can't be used
+// with it.
+func CheckInsertMode(config model.Config, insertMode model.InsertMode) error {
+	if insertMode != model.InsertModeConflict {
+		return nil
+	}
+
+	for _, table := range config.Tables {
+		if c, ok := lo.Find(table.Columns, func(c model.Column) bool { return c.Mode == model.ColumnTypeCumulative }); ok {
+			return fmt.Errorf("cumulative column %s.%s can't be used with --insert-mode conflict, as skipped rows would still count towards its totals", table.Name, c.Name)
+		}
+	}
+
+	return nil
+}
+
+// deriveTotal adds the value of a cumulative column's source to the total
+// for the row's per column value, and sets the column to the new total.
+func deriveTotal(table model.Table, c model.Column, row []any, i int) error {
+	key, source, err := totalOf(table, c, row)
+	if err != nil {
+		return err
+	}
+
+	total, err := c.Total.Add(key, row[source])
+	if err != nil {
+		return err
+	}
+
+	row[i] = total
+	return nil
+}
+
+// commitTotals commits the values of written rows to the totals of each
+// of their cumulative columns.
+func commitTotals(table model.Table, rows [][]any) error {
+	for _, c := range table.Columns {
+		if c.Mode != model.ColumnTypeCumulative {
+			continue
+		}
+
+		for _, row := range rows {
+			key, source, err := totalOf(table, c, row)
+			if err != nil {
+				return fmt.Errorf("totalling %q: %w", c.Name, err)
+			}
+
+			if err = c.Total.Commit(key, row[source]); err != nil {
+				return fmt.Errorf("totalling %q: %w", c.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// totalOf returns the key of the total that a row adds to, which is the
+// row's per column value, and the index of the column that it totals.
+func totalOf(table model.Table, c model.Column, row []any) (string, int, error) {
+	_, source, ok := lo.FindIndexOf(table.Columns, func(s model.Column) bool { return s.Name == c.Cumulative })
+	if !ok {
+		return "", 0, fmt.Errorf("missing column %q to total", c.Cumulative)
+	}
+
+	var key string
+	if per := c.Total.Props().Per; per != "" {
+		_, p, ok := lo.FindIndexOf(table.Columns, func(s model.Column) bool { return s.Name == per })
+		if !ok {
+			return "", 0, fmt.Errorf("missing column %q to total by", per)
+		}
+		key = model.FormatValue(row[p])
+	}
+
+	return key, source, nil
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeriveValues(t *testing.T) {
+	namespace := uuid.MustParse("6f0c5bb4-2d1c-4a4e-9a0e-8f3c2a5b7d11")
+	props, err := model.NewRawMessage(model.UUIDv5Props{Namespace: namespace.String()})
+	assert.NoError(t, err)
+
+	table := model.Table{
+		Columns: []model.Column{
+			{Name: "email", Mode: model.ColumnTypeValue},
+			{Name: "id", Mode: model.ColumnTypeUUIDv5, UUIDv5: "email"},
+			{Name: "tenant_id", Mode: model.ColumnTypeUUIDv5, UUIDv5: "email", Props: props},
+		},
+	}
+
+	cases := []struct {
+		name string
+		row  []any
+		exp  []any
+	}{
+		{
+			name: "derived",
+			row:  []any{"jo@example.com", nil, nil},
+			exp: []any{
+				"jo@example.com",
+				random.UUIDv5(uuid.NameSpaceURL, "jo@example.com"),
+				random.UUIDv5(namespace, "jo@example.com"),
+			},
+		},
+		{
+			name: "null source",
+			row:  []any{nil, nil, nil},
+			exp:  []any{nil, nil, nil},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.NoError(t, deriveValues(table, c.row))
+			assert.Equal(t, c.exp, c.row)
+		})
+	}
+}
+
+func TestCheckInsertMode(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name: "ledger",
+				Columns: []model.Column{
+					{Name: "amount", Mode: model.ColumnTypeRange},
+					{Name: "balance", Mode: model.ColumnTypeCumulative, Cumulative: "amount"},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		insertMode model.InsertMode
+		expError   string
+	}{
+		{name: "insert", insertMode: model.InsertModeInsert},
+		{name: "upsert", insertMode: model.InsertModeUpsert},
+		{
+			name:       "conflict",
+			insertMode: model.InsertModeConflict,
+			expError:   "cumulative column ledger.balance can't be used with --insert-mode conflict, as skipped rows would still count towards its totals",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckInsertMode(config, c.insertMode)
+			if c.expError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.expError)
+		})
+	}
+}
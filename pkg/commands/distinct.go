@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+)
+
+// maxPoolAttempts is the number of values generated for each value in a
+// distinct column's pool before giving up on finding more distinct ones.
+const maxPoolAttempts = 10
+
+// preparePools generates the pools of a table's distinct columns, and
+// returns a copy of the table's columns with the pools set.
+func preparePools(table model.Table, data *model.IterationData) ([]model.Column, error) {
+	columns := slices.Clone(table.Columns)
+
+	for i, c := range columns {
+		// Deferred columns are filled in once every table exists, so can't
+		// be pooled beforehand.
+		if c.Distinct == 0 || c.Deferred {
+			continue
+		}
+
+		pool, err := distinctPool(c, data)
+		if err != nil {
+			return nil, fmt.Errorf("generating distinct values for %q: %w", c.Name, err)
+		}
+		columns[i].Pool = pool
+	}
+
+	return columns, nil
+}
+
+// distinctPool generates the column's number of distinct values. If its
+// generator doesn't produce enough distinct values, strings are derived
+// from those that it did produce.
+func distinctPool(c model.Column, data *model.IterationData) ([]any, error) {
+	pool := make([]any, 0, c.Distinct)
+	seen := map[string]struct{}{}
+
+	add := func(v any) bool {
+		key := model.FormatValue(v)
+		if _, ok := seen[key]; ok || v == nil {
+			return false
+		}
+
+		seen[key] = struct{}{}
+		pool = append(pool, v)
+		return true
+	}
+
+	// Referenced values are taken from every value that can be referenced,
+	// rather than the sample that ref columns otherwise draw from.
+	if c.Mode == model.ColumnTypeRef {
+		values := data.GetAllValues(c.RefKey())
+		random.Shuffle(values)
+
+		for i := 0; i < len(values) && len(pool) < c.Distinct; i++ {
+			add(values[i])
+		}
+	}
+
+	for attempt := 0; c.Mode != model.ColumnTypeRef && attempt < c.Distinct*maxPoolAttempts && len(pool) < c.Distinct; attempt++ {
+		v, err := generateColumn(c, data)
+		if err != nil {
+			return nil, err
+		}
+		add(v)
+	}
+
+	if len(pool) == c.Distinct {
+		return pool, nil
+	}
+
+	// Referenced values can't be derived, as the derived values wouldn't
+	// exist in the referenced column.
+	if len(pool) == 0 || c.Mode == model.ColumnTypeRef {
+		return nil, fmt.Errorf("only %d of %d distinct values could be generated", len(pool), c.Distinct)
+	}
+
+	if _, ok := pool[0].(string); !ok {
+		return nil, fmt.Errorf("only %d of %d distinct values could be generated", len(pool), c.Distinct)
+	}
+
+	generated := len(pool)
+	for n := int64(1); len(pool) < c.Distinct; n++ {
+		add(withSuffix(pool[int(n-1)%generated].(string), n))
+	}
+
+	return pool, nil
+}
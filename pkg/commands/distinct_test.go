@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/test"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistinctPool(t *testing.T) {
+	intProps, err := model.NewRawMessage(model.IntRange{Min: 1, Max: 3})
+	assert.NoError(t, err)
+
+	data := model.NewIterationData()
+	data.SeedData("person.id", []any{"1", "2"})
+
+	cases := []struct {
+		name     string
+		column   model.Column
+		exp      []any
+		expError string
+	}{
+		{
+			name:   "fewer than generated",
+			column: model.Column{Mode: model.ColumnTypeSet, Set: []string{"a"}, Distinct: 1},
+			exp:    []any{"a"},
+		},
+		{
+			name:   "derived strings",
+			column: model.Column{Mode: model.ColumnTypeValue, Value: "jo@example.com", Distinct: 3},
+			exp:    []any{"jo@example.com", "jo_1@example.com", "jo_2@example.com"},
+		},
+		{
+			name:     "too few numbers",
+			column:   model.Column{Mode: model.ColumnTypeRange, Range: "int", Props: intProps, Distinct: 5},
+			expError: "only 2 of 5 distinct values could be generated",
+		},
+		{
+			name:     "too few references",
+			column:   model.Column{Mode: model.ColumnTypeRef, Ref: "person.id", Distinct: 3},
+			expError: "only 2 of 3 distinct values could be generated",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			act, err := distinctPool(c.column, data)
+			if c.expError != "" {
+				assert.EqualError(t, err, c.expError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.exp, act)
+		})
+	}
+}
+
+func TestGenerate_Distinct(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name: "person",
+				Rows: 500,
+				Columns: []model.Column{
+					{Name: "city", Mode: model.ColumnTypeValue, Value: "${city}", Distinct: 5},
+					{Name: "code", Mode: model.ColumnTypeValue, Value: "${uuid}", Distinct: 20},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 4, 4, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["person"]
+	assert.Len(t, rows, 500)
+
+	// Every value in each pool is almost certain to be sampled.
+	assert.Len(t, lo.Uniq(lo.Map(rows, func(row []any, _ int) any { return row[0] })), 5)
+	assert.Len(t, lo.Uniq(lo.Map(rows, func(row []any, _ int) any { return row[1] })), 20)
+}
+
+func TestGenerate_DistinctRef(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "person",
+				Rows:       model.DefaultPoolSize + 1000,
+				Columns:    []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}},
+				RefColumns: []string{"id"},
+			},
+			{
+				Name:    "pet",
+				Rows:    100,
+				Columns: []model.Column{{Name: "person_id", Mode: model.ColumnTypeRef, Ref: "person.id", Distinct: model.DefaultPoolSize + 500}},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 1000, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	ids := lo.Map(writer.rows["person"], func(row []any, _ int) any { return row[0] })
+	for _, row := range writer.rows["pet"] {
+		assert.Contains(t, ids, row[0])
+	}
+}
@@ -136,6 +136,11 @@ func toConfigs(definitions []columnDefinition, rowCountMap map[string]int) ([]mo
 		tables = append(tables, table)
 	}
 
+	// Self-references don't need breaking, but cycles between tables do, so
+	// defer the columns that form them.
+	model.BreakCycles(tables)
+	markDeferredKeys(tables)
+
 	tables, err := model.SortTables(tables)
 	if err != nil {
 		return nil, fmt.Errorf("sorting tables into relational order: %w", err)
@@ -144,6 +149,31 @@ func toConfigs(definitions []columnDefinition, rowCountMap map[string]int) ([]mo
 	return tables, nil
 }
 
+// markDeferredKeys marks the columns that foreign keys reference as unique,
+// in tables with deferred columns, so that their rows can be identified
+// when the deferred columns are filled in. The database requires these
+// columns to be unique anyway.
+func markDeferredKeys(tables []model.Table) {
+	for i := range tables {
+		if !lo.SomeBy(tables[i].Columns, func(c model.Column) bool { return c.Deferred }) {
+			continue
+		}
+
+		for _, t := range tables {
+			for _, c := range t.Columns {
+				refTable, refColumn, ok := strings.Cut(c.Ref, ".")
+				if !ok || refTable != tables[i].Name {
+					continue
+				}
+
+				if _, j, ok := lo.FindIndexOf(tables[i].Columns, func(c model.Column) bool { return c.Name == refColumn }); ok {
+					tables[i].Columns[j].Unique = true
+				}
+			}
+		}
+	}
+}
+
 func createRefColumn(c columnDefinition) model.Column {
 	return model.Column{
 		Name: c.ColumnName,
@@ -3,16 +3,16 @@ package commands
 import (
 	"context"
 	"fmt"
+	"maps"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/codingconcepts/dgs/pkg/model"
-	"github.com/codingconcepts/dgs/pkg/query"
+	"github.com/codingconcepts/dgs/pkg/output"
 	"github.com/codingconcepts/dgs/pkg/random"
 	"github.com/dustin/go-humanize"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 	"github.com/samber/lo"
 	"golang.org/x/sync/errgroup"
@@ -20,27 +20,50 @@ import (
 
 // DataGenerator holds the runtime dependencies of gen data.
 type DataGenerator struct {
-	db         *pgxpool.Pool
+	writer     output.Writer
 	logger     zerolog.Logger
 	config     model.Config
 	workers    int
+	writers    int
 	batch      int
-	insertMode model.InsertMode
+	queueSize  int
+	retry      RetryPolicy
+	checkpoint CheckpointPolicy
 
 	generatedMu sync.RWMutex
 	generated   map[string]int
+	retries     map[string]int
+
+	// series holds the state of each series table being generated, so that
+	// checkpoints can record how far each series has got.
+	series map[string]*seriesState
+
+	// checkpointMu is held while a checkpoint is saved, so that writers
+	// don't wait for checkpoints, and only one is saved at a time.
+	checkpointMu   sync.Mutex
+	checkpointedAt time.Time
+	deferredDone   bool
 }
 
 // NewDataGenerator returns a pointer to a new instance of DataGenerator.
-func NewDataGenerator(db *pgxpool.Pool, logger zerolog.Logger, config model.Config, workers, batch int, insertMode model.InsertMode) *DataGenerator {
+// Each table is generated by workers goroutines, which queue batches of
+// up to queueSize rows for writers goroutines to write. Failed writes are
+// retried according to the retry policy, and progress is recorded
+// according to the checkpoint policy.
+func NewDataGenerator(writer output.Writer, logger zerolog.Logger, config model.Config, workers, writers, batch, queueSize int, retry RetryPolicy, checkpoint CheckpointPolicy) *DataGenerator {
 	return &DataGenerator{
-		db:         db,
+		writer:     writer,
 		logger:     logger,
 		config:     config,
 		workers:    workers,
+		writers:    writers,
 		batch:      batch,
-		insertMode: insertMode,
+		queueSize:  queueSize,
+		retry:      retry,
+		checkpoint: checkpoint,
 		generated:  map[string]int{},
+		retries:    map[string]int{},
+		series:     map[string]*seriesState{},
 	}
 }
 
@@ -48,12 +71,18 @@ func (g *DataGenerator) calculateIterations() map[string]iteration {
 	iterations := map[string]iteration{}
 
 	for _, t := range g.config.Tables {
-		i := calculateIteration(t, g.batch, g.workers)
+		// Only generate the rows that a resumed run hasn't already written.
+		remaining := t
+		remaining.Rows = max(t.Rows-g.generated[t.Name], 0)
+
+		i := calculateIteration(remaining, g.batchFor(t), g.workersFor(t))
 		g.logger.Info().
 			Str("table", t.Name).
-			Int("rows", t.Rows).
+			Int("rows", remaining.Rows).
+			Int("workers", g.workersFor(t)).
 			Int("batch", i.batch).
 			Int("times", i.times).
+			Int("remainder", i.remainder).
 			Msg("iteration")
 
 		iterations[t.Name] = i
@@ -62,183 +91,851 @@ func (g *DataGenerator) calculateIterations() map[string]iteration {
 	return iterations
 }
 
+// calculateIteration splits a table's rows into batches. Batches are made
+// small enough for every worker to receive at least one (up to the batch
+// size), and any rows that don't fill a whole batch are generated in a
+// final partial batch, so exactly table.Rows rows are generated.
 func calculateIteration(table model.Table, batch, workers int) iteration {
-	if table.Rows < batch/workers {
-		return iteration{
-			times: 1,
-			batch: table.Rows / workers,
-		}
+	if table.Rows <= 0 {
+		return iteration{}
 	}
 
-	if table.Rows <= batch {
-		return iteration{
-			batch: table.Rows / workers,
-			times: 1,
-		}
-	}
-
-	if table.Rows/batch/workers == 0 {
-		return iteration{
-			batch: batch,
-			times: 1,
-		}
-	}
+	size := min(batch, (table.Rows+workers-1)/workers)
 
 	return iteration{
-		batch: batch,
-		times: table.Rows / batch / workers,
+		batch:     size,
+		times:     table.Rows / size,
+		remainder: table.Rows % size,
 	}
 }
 
+// batchFor returns the table's batch size if set, or the default batch size.
+func (g *DataGenerator) batchFor(table model.Table) int {
+	return lo.Ternary(table.Batch > 0, table.Batch, g.batch)
+}
+
+// workersFor returns the table's worker count if set, or the default
+// worker count.
+func (g *DataGenerator) workersFor(table model.Table) int {
+	return lo.Ternary(table.Workers > 0, table.Workers, g.workers)
+}
+
+// writersFor returns the table's writer count if set, or the default
+// writer count.
+func (g *DataGenerator) writersFor(table model.Table) int {
+	return lo.Ternary(table.Writers > 0, table.Writers, g.writers)
+}
+
 type iteration struct {
-	times int
-	batch int
+	times     int
+	batch     int
+	remainder int
+}
+
+// rows returns the total number of rows in the iteration.
+func (i iteration) rows() int {
+	return i.times*i.batch + i.remainder
+}
+
+// batches returns a channel that yields the size of each batch in the
+// iteration, closing it once all batches have been yielded.
+func (i iteration) batches() <-chan int {
+	ch := make(chan int)
+
+	go func() {
+		defer close(ch)
+
+		for n := 0; n < i.times; n++ {
+			ch <- i.batch
+		}
+
+		if i.remainder > 0 {
+			ch <- i.remainder
+		}
+	}()
+
+	return ch
 }
 
-func (g *DataGenerator) Generate() error {
+// Generate generates every table in the config. If ctx is cancelled, no
+// new batches are started, but batches that are already being written are
+// allowed to finish. A summary of the rows written is logged either way.
+func (g *DataGenerator) Generate(ctx context.Context) error {
+	if _, err := model.SortTables(g.config.Tables); err != nil {
+		return fmt.Errorf("checking table dependencies: %w", err)
+	}
+
+	data := model.NewIterationData()
+	g.retain(data)
+	g.seed(data)
+	g.restore(data)
+
 	iterations := g.calculateIterations()
 
 	g.logger.Info().
 		Int("workers", g.workers).
+		Int("writers", g.writers).
 		Int("batch", g.batch).
+		Int("queue size", g.queueSize).
 		Msg("generating")
 
-	var eg errgroup.Group
+	defer g.logSummary()
 
-	for w := 0; w < g.workers; w++ {
-		workerID := w + 1
+	// Tables are generated concurrently, with each table waiting for the
+	// tables it references to complete before starting.
+	dependencies := model.TableDependencies(g.config.Tables)
+
+	done := make(map[string]chan struct{}, len(g.config.Tables))
+	for _, table := range g.config.Tables {
+		done[table.Name] = make(chan struct{})
+	}
+
+	eg, gctx := errgroup.WithContext(ctx)
+
+	for _, table := range g.config.Tables {
 		eg.Go(func() error {
-			if err := g.generateWorker(iterations, workerID); err != nil {
-				return fmt.Errorf("generate worker: %w", err)
+			for _, parent := range dependencies[table.Name] {
+				g.logger.Debug().Str("table", table.Name).Str("parent", parent).Msg("waiting")
+
+				select {
+				case <-done[parent]:
+				case <-gctx.Done():
+					return nil
+				}
+			}
+
+			if err := g.generateTable(gctx, table, iterations[table.Name], data); err != nil {
+				return fmt.Errorf("generating data: %w", err)
 			}
 
+			close(done[table.Name])
 			return nil
 		})
 	}
 
-	if err := eg.Wait(); err != nil {
-		return fmt.Errorf("generating data: %w", err)
+	err := eg.Wait()
+
+	// Fill in deferred columns, now that the rows they reference exist.
+	if err == nil && ctx.Err() == nil {
+		if err = g.updateDeferred(ctx, data); err == nil {
+			g.checkpointMu.Lock()
+			g.deferredDone = true
+			g.checkpointMu.Unlock()
+		}
+	}
+
+	// Record the final progress, whether generation succeeded or not.
+	checkpointErr := g.saveCheckpoint(data, true)
+
+	if err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("generation cancelled: %w", err)
+	}
+
+	if checkpointErr != nil {
+		return fmt.Errorf("saving checkpoint: %w", checkpointErr)
+	}
+
+	return nil
+}
+
+// updateDeferred fills in the deferred columns of every table, in the rows
+// written by this run. Rows are identified by the table's unique key, whose
+// every written value is retained.
+func (g *DataGenerator) updateDeferred(ctx context.Context, data *model.IterationData) error {
+	for _, table := range g.config.Tables {
+		for _, c := range table.Columns {
+			if !c.Deferred {
+				continue
+			}
+
+			updater, ok := g.writer.(output.Updater)
+			if !ok {
+				g.logger.Warn().
+					Str("table", table.Name).
+					Str("column", c.Name).
+					Msg("deferred column left NULL, as the output can't be updated")
+				continue
+			}
+
+			key, ok := table.UniqueKey()
+			if !ok {
+				return fmt.Errorf("updating deferred column %s.%s: table has no unique column to identify rows by", table.Name, c.Name)
+			}
+
+			keys := lo.Filter(data.GetGeneratedValues(fmt.Sprintf("%s.%s", table.Name, key)), func(k any, _ int) bool { return k != nil })
+			values := data.GetAllValues(c.RefKey())
+
+			updated, err := updater.Update(ctx, table, c.Name, key, keys, g.batchFor(table), func() any {
+				return random.Sample(values)
+			})
+			if err != nil {
+				return fmt.Errorf("updating deferred column %s.%s: %w", table.Name, c.Name, err)
+			}
+
+			g.logger.Info().
+				Str("table", table.Name).
+				Str("column", c.Name).
+				Int("rows", updated).
+				Msg("updated deferred column")
+		}
+	}
+
+	return nil
+}
+
+// retain keeps every written value of the columns whose every row matters,
+// rather than only a sample of them. These are the keys of rows whose
+// deferred columns are filled in once every table has been written, and
+// the columns referenced by deferred columns, edges, and unique ref
+// columns, which can take any value that has been written.
+func (g *DataGenerator) retain(data *model.IterationData) {
+	for _, table := range g.config.Tables {
+		if lo.SomeBy(table.Columns, func(c model.Column) bool { return c.Deferred }) {
+			if key, ok := table.UniqueKey(); ok {
+				data.Retain(fmt.Sprintf("%s.%s", table.Name, key))
+			}
+		}
+
+		for _, c := range table.Columns {
+			if c.Ref == "" {
+				continue
+			}
+
+			edge := table.Edges != nil && (c.Name == table.Edges.From || c.Name == table.Edges.To)
+			if c.Deferred || edge || c.Distinct > 0 || c.Unique || lo.Contains(table.Unique, c.Name) {
+				data.Retain(c.RefKey())
+			}
+		}
+	}
+}
+
+// seed makes values that existed before generation available to ref
+// columns.
+func (g *DataGenerator) seed(data *model.IterationData) {
+	external := map[string]struct{}{}
+
+	for _, table := range g.config.Tables {
+		for column, values := range table.Existing {
+			data.SeedData(fmt.Sprintf("%s.%s", table.Name, column), values)
+		}
+
+		// Columns can share a query or file, in which case its values are
+		// seeded once.
+		for _, c := range table.Columns {
+			if _, ok := external[c.RefKey()]; ok || c.Mode != model.ColumnTypeRef || c.Ref != "" {
+				continue
+			}
+
+			data.SeedData(c.RefKey(), c.RefValues)
+			external[c.RefKey()] = struct{}{}
+		}
+	}
+}
+
+// restore continues from the checkpoint being resumed, if there is one, by
+// restoring each table's written row count, inc sequences (including the
+// sequence of each key of per sequences), running totals, series
+// positions, and referenced values.
+func (g *DataGenerator) restore(data *model.IterationData) {
+	if g.checkpoint.Resume == nil {
+		return
 	}
 
-	for k, v := range g.generated {
+	for _, table := range g.config.Tables {
+		t, ok := g.checkpoint.Resume.Tables[table.Name]
+		if !ok {
+			continue
+		}
+
+		g.generated[table.Name] = t.Rows
+
+		for _, c := range table.Columns {
+			if next, ok := t.Sequences[c.Name]; ok && c.Mode == model.ColumnTypeInc {
+				c.NextID.Reset(next)
+			}
+
+			for key, next := range t.PerSequences[c.Name] {
+				c.NextID.ResetFor(key, next)
+			}
+
+			for key, total := range t.Totals[c.Name] {
+				c.Total.Reset(key, total)
+			}
+		}
+
+		if table.Series != nil && t.Series != nil {
+			table.Series.Existing = t.Series
+		}
+
+		data.Load(t.Refs)
+
 		g.logger.Info().
-			Str("table", k).
-			Int("rows", v).
-			Msg("finished generating")
+			Str("table", table.Name).
+			Int("rows", t.Rows).
+			Msg("resuming")
+	}
+}
+
+// saveCheckpoint records the progress of every table, if checkpoints are
+// enabled and either the checkpoint interval has elapsed or force is set.
+// Unless forced, no checkpoint is saved while another is being saved.
+func (g *DataGenerator) saveCheckpoint(data *model.IterationData, force bool) error {
+	if g.checkpoint.Path == "" {
+		return nil
+	}
+
+	if force {
+		g.checkpointMu.Lock()
+	} else if !g.checkpointMu.TryLock() {
+		return nil
+	}
+	defer g.checkpointMu.Unlock()
+
+	if !force && time.Since(g.checkpointedAt) < g.checkpoint.Interval {
+		return nil
+	}
+
+	g.generatedMu.RLock()
+	generated := maps.Clone(g.generated)
+	series := maps.Clone(g.series)
+	g.generatedMu.RUnlock()
+
+	refs := data.Snapshot(func(ref string) bool {
+		return g.refNeeded(ref, generated)
+	})
+
+	c := Checkpoint{
+		Seed:   g.checkpoint.Seed,
+		Tables: make(map[string]TableCheckpoint, len(g.config.Tables)),
+	}
+
+	for _, table := range g.config.Tables {
+		t := TableCheckpoint{
+			Rows:         generated[table.Name],
+			Sequences:    map[string]int64{},
+			PerSequences: map[string]map[string]int64{},
+			Totals:       map[string]map[string]model.Total{},
+			Refs:         map[string][]any{},
+		}
+
+		for _, col := range table.Columns {
+			switch col.Mode {
+			case model.ColumnTypeInc:
+				t.Sequences[col.Name] = col.NextID.Position()
+
+				// Each key's sequence is only needed to number rows left to
+				// generate.
+				if col.NextID.Props().Per != "" && t.Rows < table.Rows {
+					t.PerSequences[col.Name] = col.NextID.Positions()
+				}
+
+			case model.ColumnTypeCumulative:
+				if t.Rows < table.Rows {
+					t.Totals[col.Name] = col.Total.Totals()
+				}
+			}
+		}
+
+		// As with per sequences, series positions are only needed to
+		// continue series with rows left to generate.
+		if s, ok := series[table.Name]; ok && t.Rows < table.Rows {
+			t.Series = s.positions()
+		}
+
+		for _, col := range table.RefColumns {
+			key := fmt.Sprintf("%s.%s", table.Name, col)
+			if values, ok := refs[key]; ok {
+				t.Refs[key] = values
+			}
+		}
+
+		c.Tables[table.Name] = t
 	}
 
+	if err := c.Save(g.checkpoint.Path); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+
+	g.checkpointedAt = time.Now()
 	return nil
 }
 
-func (g *DataGenerator) generateWorker(iterations map[string]iteration, wid int) error {
-	g.logger.Info().Int("worker id", wid).Msg("scheduled")
+// refNeeded returns true if a resumed run would need a referenced
+// column's values. Values are needed while any table that references them
+// has rows left to generate, or deferred columns left to fill in, and
+// while the rows of tables with deferred columns are left to update. The
+// caller must hold checkpointMu.
+func (g *DataGenerator) refNeeded(ref string, generated map[string]int) bool {
+	for _, t := range g.config.Tables {
+		if !g.deferredDone && lo.SomeBy(t.Columns, func(c model.Column) bool { return c.Deferred }) {
+			if key, ok := t.UniqueKey(); ok && ref == fmt.Sprintf("%s.%s", t.Name, key) {
+				return true
+			}
+		}
+
+		for _, c := range t.Columns {
+			if c.Ref == "" || c.RefKey() != ref {
+				continue
+			}
 
-	db, err := g.db.Acquire(context.Background())
-	if err != nil {
-		return fmt.Errorf("acquiring database connection: %w", err)
+			if generated[t.Name] < t.Rows || (c.Deferred && !g.deferredDone) {
+				return true
+			}
+		}
 	}
-	defer db.Release()
 
-	g.logger.Info().Int("worker id", wid).Msg("started")
+	return false
+}
+
+// logSummary logs the number of rows written to each table.
+func (g *DataGenerator) logSummary() {
+	g.generatedMu.RLock()
+	defer g.generatedMu.RUnlock()
 
-	data := model.NewIterationData()
 	for _, table := range g.config.Tables {
-		iter := iterations[table.Name]
+		g.logger.Info().
+			Str("table", table.Name).
+			Int("rows", g.generated[table.Name]).
+			Int("target", table.Rows).
+			Int("retries", g.retries[table.Name]).
+			Msg("finished generating")
+	}
+}
 
-		for i := 0; i < iter.times; i++ {
-			// Generate rows.
-			rows, err := g.generateRows(table, data, g.batch)
-			if err != nil {
-				return fmt.Errorf("generating rows: %w", err)
+// generateTable runs a pipeline for a table, in which workers generate
+// batches onto a bounded queue, while writers take batches from the queue
+// and write them, allowing generation and writing to overlap.
+func (g *DataGenerator) generateTable(ctx context.Context, table model.Table, iter iteration, data *model.IterationData) error {
+	g.logger.Info().Str("table", table.Name).Msg("started table")
+
+	var err error
+	if table.Columns, err = preparePools(table, data); err != nil {
+		return fmt.Errorf("generating table %q: %w", table.Name, err)
+	}
+
+	if table.Tree != nil {
+		return g.generateTreeTable(ctx, table, iter, data)
+	}
+
+	constraints, err := newRowConstraints(table, data, iter.rows())
+	if err != nil {
+		return fmt.Errorf("generating table %q: %w", table.Name, err)
+	}
+
+	if constraints.series != nil {
+		g.generatedMu.Lock()
+		g.series[table.Name] = constraints.series
+		g.generatedMu.Unlock()
+	}
+
+	batches := iter.batches()
+	queue := make(chan [][]any, g.queueSize)
+
+	eg, gctx := errgroup.WithContext(ctx)
+
+	var workers sync.WaitGroup
+	for w := 0; w < g.workersFor(table); w++ {
+		workerID := w + 1
+		workers.Add(1)
+		eg.Go(func() error {
+			defer workers.Done()
+
+			if err := g.generateWorker(gctx, table, batches, data, constraints, queue, workerID); err != nil {
+				return fmt.Errorf("generate worker: %w", err)
 			}
 
-			// Write rows.
-			if err = g.writeRows(db, table, data, rows); err != nil {
-				return fmt.Errorf("writing rows: %w", err)
+			return nil
+		})
+	}
+
+	// Close the queue once all batches have been generated, so that the
+	// writers know when to stop.
+	eg.Go(func() error {
+		workers.Wait()
+		close(queue)
+		return nil
+	})
+
+	for w := 0; w < g.writersFor(table); w++ {
+		writerID := w + 1
+		eg.Go(func() error {
+			if err := g.writeWorker(gctx, table, data, constraints, queue, writerID); err != nil {
+				return fmt.Errorf("write worker: %w", err)
 			}
 
-			g.generatedMu.Lock()
-			g.generated[table.Name] += g.batch
-			g.logger.Info().
-				Str("table", table.Name).
-				Int("worker id", wid).
-				Str("generated", humanize.Comma(int64(iter.batch))).
-				Str("total", humanize.Comma(int64(g.generated[table.Name]))).
-				Msg("progress")
-			g.generatedMu.Unlock()
+			return nil
+		})
+	}
+
+	err = eg.Wait()
+
+	// Drain remaining batches to release the producer if the table was
+	// stopped early.
+	for range batches {
+	}
+
+	if err != nil {
+		return fmt.Errorf("generating table %q: %w", table.Name, err)
+	}
+
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("generating table %q: %w", table.Name, err)
+	}
+
+	g.logger.Info().Str("table", table.Name).Msg("finished table")
+	return nil
+}
+
+func (g *DataGenerator) generateWorker(ctx context.Context, table model.Table, batches <-chan int, data *model.IterationData, constraints *rowConstraints, queue chan<- [][]any, wid int) error {
+	g.logger.Debug().Str("table", table.Name).Int("worker id", wid).Msg("started")
+
+	for batch := range batches {
+		// Stop picking up batches if a worker or writer has failed, or
+		// generation has been cancelled.
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		rows, err := g.generateRows(table, data, constraints, batch)
+		if err != nil {
+			return fmt.Errorf("generating rows: %w", err)
+		}
+
+		select {
+		case queue <- rows:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	g.logger.Debug().Str("table", table.Name).Int("worker id", wid).Msg("finished")
+
+	return nil
+}
+
+func (g *DataGenerator) writeWorker(ctx context.Context, table model.Table, data *model.IterationData, constraints *rowConstraints, queue chan [][]any, wid int) error {
+	g.logger.Debug().Str("table", table.Name).Int("writer id", wid).Msg("started")
+
+	for rows := range queue {
+		// Stop writing batches if a worker or writer has failed, or
+		// generation has been cancelled.
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err := g.writeBatch(ctx, table, data, constraints, rows, wid, len(queue)); err != nil {
+			return fmt.Errorf("writing rows: %w", err)
+		}
+	}
+
+	g.logger.Debug().Str("table", table.Name).Int("writer id", wid).Msg("finished")
+
+	return nil
+}
+
+// writeBatch writes a batch of rows. If any rows aren't written (as is the
+// case for rows that conflict with existing rows under the conflict insert
+// mode), new rows are generated and written in their place, up to the
+// retry policy's TopUps times, so that the table reaches its target row
+// count. If it can't, an error is returned.
+func (g *DataGenerator) writeBatch(ctx context.Context, table model.Table, data *model.IterationData, constraints *rowConstraints, rows [][]any, wid, queued int) error {
+	for topUp := 0; ; topUp++ {
+		written, err := g.writeRows(ctx, table, data, rows)
+		if err != nil {
+			return err
+		}
+
+		if err = g.recordProgress(table, data, written, wid, queued); err != nil {
+			return fmt.Errorf("recording progress: %w", err)
+		}
+
+		missing := len(rows) - written
+		if missing <= 0 || ctx.Err() != nil {
+			return nil
+		}
+
+		if topUp >= g.retry.TopUps {
+			return fmt.Errorf("%d rows weren't written after topping up %d times", missing, topUp)
+		}
+
+		g.logger.Debug().Str("table", table.Name).Int("rows", missing).Msg("topping up batch")
+
+		if rows, err = g.generateRows(table, data, constraints, missing); err != nil {
+			return fmt.Errorf("generating rows: %w", err)
 		}
 	}
+}
+
+// recordProgress counts a written batch of rows, and saves a checkpoint if
+// one is due.
+func (g *DataGenerator) recordProgress(table model.Table, data *model.IterationData, rows, wid, queued int) error {
+	g.generatedMu.Lock()
+	g.generated[table.Name] += rows
+	total := g.generated[table.Name]
+	g.generatedMu.Unlock()
 
-	g.logger.Info().Int("worker id", wid).Msg("finished")
+	g.logger.Info().
+		Str("table", table.Name).
+		Int("writer id", wid).
+		Str("generated", humanize.Comma(int64(rows))).
+		Str("total", humanize.Comma(int64(total))).
+		Int("queued", queued).
+		Msg("progress")
+
+	if err := g.saveCheckpoint(data, false); err != nil {
+		return fmt.Errorf("saving checkpoint: %w", err)
+	}
 
 	return nil
 }
 
-func (g *DataGenerator) generateRows(table model.Table, data *model.IterationData, batch int) ([][]any, error) {
+// rowConstraints holds the state that every worker generating a table
+// shares, to ensure that rows are never repeated, and that series
+// continue from one row to the next.
+type rowConstraints struct {
+	edges  *edgeSampler
+	unique *uniqueness
+	series *seriesState
+
+	// stateful is held while filling in the columns whose values follow on
+	// from earlier rows, so that they agree on the order of each entity's
+	// rows. It's nil if the table has no such columns.
+	stateful *sync.Mutex
+}
+
+// newRowConstraints returns the constraints on a table's rows, failing if
+// they can't be satisfied for the given number of rows.
+func newRowConstraints(table model.Table, data *model.IterationData, rows int) (*rowConstraints, error) {
+	c := rowConstraints{
+		unique: newUniqueness(table),
+		series: newSeriesState(table),
+	}
+
+	if table.Series != nil || lo.SomeBy(table.Columns, isStateful) {
+		c.stateful = &sync.Mutex{}
+	}
+
+	if table.Edges != nil && rows > 0 {
+		var err error
+		if c.edges, err = newEdgeSampler(table, data, rows); err != nil {
+			return nil, fmt.Errorf("preparing edges: %w", err)
+		}
+	}
+
+	return &c, nil
+}
+
+// continueRow fills in a row's series columns, per sequences, and running
+// totals, which follow on from the rows generated before it, along with
+// any columns derived from them.
+func (c *rowConstraints) continueRow(table model.Table, row []any) error {
+	if c.stateful != nil {
+		c.stateful.Lock()
+		defer c.stateful.Unlock()
+	}
+
+	if c.series != nil {
+		c.series.fill(row)
+	}
+
+	// Tree tables number rows once their tree columns are known.
+	if table.Tree != nil {
+		return nil
+	}
+
+	if err := deriveSequences(table, row); err != nil {
+		return fmt.Errorf("numbering row: %w", err)
+	}
+
+	if err := deriveTotals(table, row); err != nil {
+		return fmt.Errorf("totalling row: %w", err)
+	}
+
+	return deriveValues(table, row)
+}
+
+// isStateful returns true if a column's values follow on from those of
+// earlier rows.
+func isStateful(c model.Column) bool {
+	perInc := c.Mode == model.ColumnTypeInc && c.NextID != nil && c.NextID.Props().Per != ""
+	return perInc || c.Mode == model.ColumnTypeCumulative
+}
+
+// generateRows generates a batch of rows that satisfy the table's
+// constraints.
+func (g *DataGenerator) generateRows(table model.Table, data *model.IterationData, constraints *rowConstraints, batch int) ([][]any, error) {
 	rows := [][]any{}
+	selfRefs := selfReferences(table)
 
 	for i := 0; i < batch; i++ {
 		row, err := g.generateRow(table.Columns, data)
 		if err != nil {
 			return nil, fmt.Errorf("generating row: %w", err)
 		}
-		rows = append(rows, row)
-	}
 
-	return rows, nil
-}
+		if constraints.edges != nil {
+			if err = constraints.edges.fill(row); err != nil {
+				return nil, fmt.Errorf("generating edge: %w", err)
+			}
+		}
 
-func (g *DataGenerator) generateRow(columns []model.Column, data *model.IterationData) ([]any, error) {
-	row := []any{}
+		// Self-referencing columns form trees, in which rows reference either
+		// a row written in an earlier batch, or an earlier row in this batch.
+		// The first row of each batch is a root (NULL), so that the number of
+		// roots doesn't depend on how many rows have been written so far.
+		for col, ref := range selfRefs {
+			earlier := int64(len(rows))
+			if earlier == 0 {
+				row[col] = nil
+				continue
+			}
 
-	for _, c := range columns {
-		switch c.Mode {
-		case model.ColumnTypeArray:
-			var x model.IntRange
-			if err := c.Props.Unmarshal(&x); err != nil {
-				return nil, fmt.Errorf("decoding int array props: %w", err)
+			written := int64(len(data.GetValues(table.Columns[col].Ref)))
+			if random.Int(0, earlier+written) < earlier {
+				row[col] = rows[random.Int(0, earlier)][ref]
 			}
+		}
 
-			a := random.Array(x.Min, x.Max, c.Array)
-			pga := pgtype.Array[any]{
-				Elements: a,
-				Valid:    true,
-				Dims:     []pgtype.ArrayDimension{{Length: int32(len(a)), LowerBound: 1}},
+		// Tree tables derive values once their tree columns are known.
+		if table.Tree == nil {
+			if err = deriveValues(table, row); err != nil {
+				return nil, fmt.Errorf("deriving values: %w", err)
 			}
-			row = append(row, pga)
+		}
 
-		case model.ColumnTypeValue:
-			val, err := generateValue(c)
-			if err != nil {
-				return nil, fmt.Errorf("generating value: %w", err)
+		if constraints.unique != nil {
+			if err = g.makeUnique(table, data, constraints.unique, row); err != nil {
+				return nil, fmt.Errorf("making row unique: %w", err)
 			}
-			row = append(row, val)
+		}
 
-		case model.ColumnTypeRange:
-			val, err := generateRange(c)
-			if err != nil {
-				return nil, fmt.Errorf("generating range: %w", err)
+		// Values that follow on from earlier rows can't be taken back, so
+		// are only filled in once the row's other values are final.
+		if err = constraints.continueRow(table, row); err != nil {
+			return nil, fmt.Errorf("continuing row: %w", err)
+		}
+
+		if constraints.unique != nil {
+			if err = constraints.unique.check(row); err != nil {
+				return nil, fmt.Errorf("making row unique: %w", err)
 			}
-			row = append(row, val)
+		}
 
-		case model.ColumnTypeSet:
-			row = append(row, lo.Sample(c.Set))
+		rows = append(rows, row)
+	}
 
-		case model.ColumnTypeRef:
-			row = append(row, data.GetValue(c.Ref))
+	return rows, nil
+}
 
-		case model.ColumnTypeInc:
-			row = append(row, c.NextID())
+// selfReferences returns the index of each of a table's columns that
+// reference another column in the same table, mapped to the index of the
+// column they reference.
+func selfReferences(table model.Table) map[int]int {
+	refs := map[int]int{}
 
-		default:
-			return nil, fmt.Errorf("invalid column mode: %q", c.Mode)
+	for i, c := range table.Columns {
+		refTable, refColumn, ok := strings.Cut(c.Ref, ".")
+		if !ok || refTable != table.Name || c.Deferred {
+			continue
+		}
+
+		if j := lo.IndexOf(lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name }), refColumn); j != -1 {
+			refs[i] = j
 		}
 	}
 
+	return refs
+}
+
+func (g *DataGenerator) generateRow(columns []model.Column, data *model.IterationData) ([]any, error) {
+	row := []any{}
+
+	for _, c := range columns {
+		val, err := generateColumn(c, data)
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, val)
+	}
+
 	g.logger.Debug().Msgf("row: %+v", row)
 	return row, nil
 }
 
+// generateColumn generates a single value for a column.
+func generateColumn(c model.Column, data *model.IterationData) (any, error) {
+	// Distinct columns only take values from their pool.
+	if c.Pool != nil {
+		return random.Sample(c.Pool), nil
+	}
+
+	switch c.Mode {
+	case model.ColumnTypeArray:
+		var x model.IntRange
+		if err := c.Props.Unmarshal(&x); err != nil {
+			return nil, fmt.Errorf("decoding int array props: %w", err)
+		}
+
+		a := random.Array(x.Min, x.Max, c.Array)
+		return pgtype.Array[any]{
+			Elements: a,
+			Valid:    true,
+			Dims:     []pgtype.ArrayDimension{{Length: int32(len(a)), LowerBound: 1}},
+		}, nil
+
+	case model.ColumnTypeValue:
+		val, err := generateValue(c)
+		if err != nil {
+			return nil, fmt.Errorf("generating value: %w", err)
+		}
+		return val, nil
+
+	case model.ColumnTypeRange:
+		val, err := generateRange(c)
+		if err != nil {
+			return nil, fmt.Errorf("generating range: %w", err)
+		}
+		return val, nil
+
+	case model.ColumnTypeSet:
+		return random.Sample(c.Set), nil
+
+	case model.ColumnTypeRef:
+		// Deferred columns are filled in once every table exists.
+		if c.Deferred {
+			return nil, nil
+		}
+		return data.GetValue(c.RefKey()), nil
+
+	case model.ColumnTypeInc:
+		// Sequences per another column are computed once the rest of the
+		// row is known.
+		if c.NextID.Props().Per != "" {
+			return nil, nil
+		}
+		return c.NextID.Value(c.NextID.Next()), nil
+
+	case model.ColumnTypeTree:
+		// Tree columns are computed once the tree's shape is known.
+		return nil, nil
+
+	case model.ColumnTypeSeries:
+		// Series columns are computed once the row's series is known.
+		return nil, nil
+
+	case model.ColumnTypeUUIDv5, model.ColumnTypeCumulative:
+		// Derived columns are computed once the rest of the row is known.
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("invalid column mode: %q", c.Mode)
+	}
+}
+
 func generateRange(c model.Column) (any, error) {
 	switch x := strings.ToLower(c.Range); x {
 	case "int":
@@ -332,36 +1029,89 @@ func generateValue(c model.Column) (any, error) {
 	return value, nil
 }
 
-func (g *DataGenerator) writeRows(db *pgxpool.Conn, table model.Table, data *model.IterationData, rows [][]any) error {
-	stmt, err := query.BuildInsert(table, rows, g.insertMode)
-	if err != nil {
-		return fmt.Errorf("building insert: %w", err)
+// writeRows writes a batch of rows, making the values of its referenced
+// columns available to other tables, and returns the number of rows that
+// were written. Writers that can skip rows report the rows that they
+// wrote, so that only those are referenced. Rows that a writer skips after
+// an ambiguous retry may have been written by an earlier attempt, so they
+// count as written, but aren't referenced.
+func (g *DataGenerator) writeRows(ctx context.Context, table model.Table, data *model.IterationData, rows [][]any) (int, error) {
+	names := lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name })
+	columns := lo.Intersect(lo.Uniq(table.RefColumns), names)
+	indexes := lo.Map(columns, func(column string, _ int) int { return lo.IndexOf(names, column) })
+
+	// Batches that have started writing are allowed to finish, even if
+	// generation is cancelled, so only retries observe cancellation.
+	writeCtx := context.WithoutCancel(ctx)
+
+	written := rows
+
+	write := func() error {
+		return g.writer.Write(writeCtx, table, rows)
+	}
+
+	// Writers that return rows only return the referenced columns.
+	if returner, ok := g.writer.(output.Returner); ok {
+		indexes = lo.Range(len(columns))
+		write = func() (err error) {
+			written, err = returner.WriteReturning(writeCtx, table, rows, columns)
+			return err
+		}
+	}
+
+	var ambiguous bool
+	onRetry := func(attempt int, err error) {
+		ambiguous = ambiguous || isAmbiguous(err)
+
+		g.generatedMu.Lock()
+		g.retries[table.Name]++
+		g.generatedMu.Unlock()
+
+		g.logger.Warn().
+			Str("table", table.Name).
+			Int("attempt", attempt).
+			Str("sqlstate", sqlState(err)).
+			Err(err).
+			Msg("retrying batch")
 	}
-	g.logger.Debug().Str("stmt", stmt).Msg("running insert")
 
-	timeout, cancel := context.WithTimeout(context.Background(), time.Second*30)
-	defer cancel()
+	if err := g.retry.retry(ctx, write, onRetry); err != nil {
+		g.logger.Error().
+			Str("table", table.Name).
+			Str("sqlstate", sqlState(err)).
+			Err(err).
+			Msg("failed to write batch")
 
-	if _, err := db.Exec(timeout, stmt, lo.Flatten(rows)...); err != nil {
-		return fmt.Errorf("executing query: %w", err)
+		return 0, fmt.Errorf("writing batch: %w", err)
 	}
 
-	// Return the generated rows that match the columns that other tables reference.
-	for _, column := range table.RefColumns {
-		_, index, ok := lo.FindIndexOf(table.Columns, func(c model.Column) bool {
-			return c.Name == column
-		})
+	// Tables with cumulative columns can't skip rows, so every row in the
+	// batch has been written.
+	if err := commitTotals(table, rows); err != nil {
+		return 0, fmt.Errorf("committing totals: %w", err)
+	}
 
-		if !ok {
-			continue
+	// Add the written rows' values to the columns that other tables
+	// reference, alongside those of earlier batches.
+	for i, column := range columns {
+		if len(written) == 0 {
+			break
 		}
 
-		data.AddData(rows, table.Name, column, index)
+		data.AddData(written, table.Name, column, indexes[i])
 		g.logger.Debug().
 			Str("column", column).
-			Any("values", data.GetValues(fmt.Sprintf("%s.%s", table.Name, column))).
+			Int("values", len(written)).
 			Msg("persisting ref column")
 	}
 
-	return nil
+	if ambiguous && len(written) < len(rows) {
+		g.logger.Warn().
+			Str("table", table.Name).
+			Int("rows", len(rows)-len(written)).
+			Msg("rows skipped after an ambiguous result, assuming they were written")
+		return len(rows), nil
+	}
+
+	return len(written), nil
 }
@@ -1,10 +1,18 @@
 package commands
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/codingconcepts/dgs/pkg/model"
 	"github.com/codingconcepts/dgs/pkg/test"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -71,9 +79,9 @@ func TestCalculateIterations(t *testing.T) {
 			batch:   1000,
 			workers: 4,
 			exp: map[string]iteration{
-				"a": {batch: 250, times: 1},
-				"b": {batch: 1000, times: 1},
-				"c": {batch: 1000, times: 1},
+				"a": {batch: 250, times: 4},
+				"b": {batch: 500, times: 4},
+				"c": {batch: 1000, times: 4},
 			},
 		},
 		{
@@ -101,9 +109,39 @@ func TestCalculateIterations(t *testing.T) {
 			batch:   10000,
 			workers: 4,
 			exp: map[string]iteration{
-				"a": {batch: 250, times: 1},
-				"b": {batch: 500, times: 1},
-				"c": {batch: 1000, times: 1},
+				"a": {batch: 250, times: 4},
+				"b": {batch: 500, times: 4},
+				"c": {batch: 1000, times: 4},
+			},
+		},
+		{
+			name: "uneven rows",
+			tables: []model.Table{
+				{Name: "a", Rows: 1003},
+				{Name: "b", Rows: 3},
+				{Name: "c", Rows: 0},
+			},
+			batch:   100,
+			workers: 4,
+			exp: map[string]iteration{
+				"a": {batch: 100, times: 10, remainder: 3},
+				"b": {batch: 1, times: 3},
+				"c": {},
+			},
+		},
+		{
+			name: "table overrides",
+			tables: []model.Table{
+				{Name: "a", Rows: 1000, Batch: 10},
+				{Name: "b", Rows: 1000, Workers: 10},
+				{Name: "c", Rows: 1000, Batch: 500, Workers: 1},
+			},
+			batch:   1000,
+			workers: 4,
+			exp: map[string]iteration{
+				"a": {batch: 10, times: 100},
+				"b": {batch: 100, times: 10},
+				"c": {batch: 500, times: 2},
 			},
 		},
 	}
@@ -111,6 +149,7 @@ func TestCalculateIterations(t *testing.T) {
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			sut := &DataGenerator{
+				logger:  test.NewNilLogger(),
 				batch:   c.batch,
 				workers: c.workers,
 				config: model.Config{
@@ -124,6 +163,1077 @@ func TestCalculateIterations(t *testing.T) {
 	}
 }
 
+func TestIterationBatches(t *testing.T) {
+	iter := iteration{batch: 100, times: 3, remainder: 7}
+
+	var act []int
+	for b := range iter.batches() {
+		act = append(act, b)
+	}
+
+	assert.Equal(t, []int{100, 100, 100, 7}, act)
+}
+
+func TestGenerate_ExactRowCounts(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "a",
+				Rows:       1003,
+				Columns:    []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}},
+				RefColumns: []string{"id"},
+			},
+			{
+				Name:    "b",
+				Rows:    7,
+				Columns: []model.Column{{Name: "a_id", Mode: model.ColumnTypeRef, Ref: "a.id"}},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 4, 4, 100, 8, RetryPolicy{}, CheckpointPolicy{})
+
+	assert.NoError(t, sut.Generate(context.Background()))
+	assert.Len(t, writer.rows["a"], 1003)
+	assert.Len(t, writer.rows["b"], 7)
+	assert.Equal(t, map[string]int{"a": 1003, "b": 7}, sut.generated)
+
+	for _, row := range writer.rows["b"] {
+		assert.NotNil(t, row[0])
+	}
+}
+
+func TestGenerate_Dependencies(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "a",
+				Rows:       10,
+				Columns:    []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}},
+				RefColumns: []string{"id"},
+			},
+			{
+				Name:       "b",
+				Rows:       10,
+				Columns:    []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}},
+				RefColumns: []string{"id"},
+			},
+			{
+				Name: "c",
+				Rows: 10,
+				Columns: []model.Column{
+					{Name: "a_id", Mode: model.ColumnTypeRef, Ref: "a.id"},
+					{Name: "b_id", Mode: model.ColumnTypeRef, Ref: "b.id"},
+				},
+			},
+		},
+	}
+
+	// Table a can't finish until table b has started, which will only
+	// happen if independent tables are generated concurrently.
+	bStarted := make(chan struct{})
+	var bOnce sync.Once
+
+	writer := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			switch table {
+			case "a":
+				select {
+				case <-bStarted:
+				case <-time.After(time.Second * 5):
+					return fmt.Errorf("timed out waiting for b")
+				}
+			case "b":
+				bOnce.Do(func() { close(bStarted) })
+			}
+			return nil
+		},
+	}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 5, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	// Table c must only be written once a and b are complete.
+	firstC := lo.IndexOf(writer.order, "c")
+	assert.Equal(t, 2, lo.Count(writer.order[:firstC], "a"))
+	assert.Equal(t, 2, lo.Count(writer.order[:firstC], "b"))
+	assert.Equal(t, 2, lo.Count(writer.order[firstC:], "c"))
+}
+
+func TestGenerate_RefSpread(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "parent",
+				Rows:       1001,
+				Columns:    []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}},
+				RefColumns: []string{"id"},
+			},
+			{
+				Name:    "child",
+				Rows:    1000,
+				Columns: []model.Column{{Name: "parent_id", Mode: model.ColumnTypeRef, Ref: "parent.id"}},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	// The parent's final batch holds a single row, which mustn't be the
+	// only row that children can reference.
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 1000, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	referenced := lo.Uniq(lo.Map(writer.rows["child"], func(row []any, _ int) any { return row[0] }))
+
+	// Sampling 1000 times from 1001 parents references about 630 of them.
+	assert.Greater(t, len(referenced), 500)
+}
+
+func TestGenerate_WriterError(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{Name: "a", Rows: 10000, Columns: []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}}},
+			{Name: "b", Rows: 10000, Columns: []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}}},
+		},
+	}
+
+	writer := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			return fmt.Errorf("write failed")
+		},
+	}
+
+	// Generation must stop, rather than blocking on a full queue.
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 4, 2, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.ErrorContains(t, sut.Generate(context.Background()), "write failed")
+}
+
+func TestGenerate_Cancelled(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{Name: "a", Rows: 10000, Columns: []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}}},
+			{Name: "b", Rows: 10, Columns: []model.Column{{Name: "a_id", Mode: model.ColumnTypeRef, Ref: "a.id"}}},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel while the first batch is being written.
+	writer := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			cancel()
+			return nil
+		},
+	}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 4, 1, 10, 4, RetryPolicy{}, CheckpointPolicy{})
+	assert.ErrorIs(t, sut.Generate(ctx), context.Canceled)
+
+	// The in-flight batch is written and counted, but no more are started.
+	assert.Len(t, writer.rows["a"], 10)
+	assert.Equal(t, 10, sut.generated["a"])
+	assert.Empty(t, writer.rows["b"])
+}
+
+func TestGenerate_Resume(t *testing.T) {
+	newConfig := func() model.Config {
+		return model.Config{
+			Tables: []model.Table{
+				{Name: "a", Rows: 100, RefColumns: []string{"id"}, Columns: []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}}},
+				{Name: "b", Rows: 50, Columns: []model.Column{{Name: "a_id", Mode: model.ColumnTypeRef, Ref: "a.id"}}},
+			},
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	policy := CheckpointPolicy{Path: path, Seed: 42}
+
+	// Interrupt the first run after its first batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			cancel()
+			return nil
+		},
+	}
+
+	sut := NewDataGenerator(first, test.NewNilLogger(), newConfig(), 4, 1, 10, 4, RetryPolicy{}, policy)
+	assert.ErrorIs(t, sut.Generate(ctx), context.Canceled)
+
+	cp, err := LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), cp.Seed)
+	assert.Equal(t, 10, cp.Tables["a"].Rows)
+	assert.Len(t, cp.Tables["a"].Refs["a.id"], 10)
+
+	// Resume with a freshly parsed config.
+	policy.Resume = &cp
+	second := &recordingWriter{rows: map[string][][]any{}}
+
+	sut = NewDataGenerator(second, test.NewNilLogger(), newConfig(), 4, 1, 10, 4, RetryPolicy{}, policy)
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	ids := lo.Map(append(first.rows["a"], second.rows["a"]...), func(row []any, _ int) any {
+		return row[0]
+	})
+	assert.Len(t, ids, 100)
+	assert.Len(t, lo.Uniq(ids), 100)
+
+	assert.Len(t, second.rows["b"], 50)
+	for _, row := range second.rows["b"] {
+		assert.Contains(t, ids, row[0])
+	}
+
+	cp, err = LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 100, cp.Tables["a"].Rows)
+	assert.Equal(t, 50, cp.Tables["b"].Rows)
+
+	// Once every table is complete, no values need to be referenced.
+	assert.Empty(t, cp.Tables["a"].Refs)
+}
+
+func TestGenerate_ResumePerSequence(t *testing.T) {
+	newConfig := func() model.Config {
+		return model.Config{
+			Tables: []model.Table{
+				{
+					Name: "order_line",
+					Rows: 40,
+					Columns: []model.Column{
+						{Name: "order_id", Mode: model.ColumnTypeSet, Set: []string{"a", "b"}},
+						{Name: "line", Mode: model.ColumnTypeInc, NextID: lo.Must(model.NewSequence(1, model.IncProps{Per: "order_id"}))},
+					},
+				},
+			},
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	policy := CheckpointPolicy{Path: path}
+
+	// Interrupt the first run after its first batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			cancel()
+			return nil
+		},
+	}
+
+	sut := NewDataGenerator(first, test.NewNilLogger(), newConfig(), 1, 1, 10, 1, RetryPolicy{}, policy)
+	assert.ErrorIs(t, sut.Generate(ctx), context.Canceled)
+
+	cp, err := LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cp.Tables["order_line"].PerSequences["line"])
+
+	policy.Resume = &cp
+	second := &recordingWriter{rows: map[string][][]any{}}
+
+	sut = NewDataGenerator(second, test.NewNilLogger(), newConfig(), 1, 1, 10, 1, RetryPolicy{}, policy)
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	// Each order's lines continue from where the first run left off.
+	lines := lo.Map(append(first.rows["order_line"], second.rows["order_line"]...), func(row []any, _ int) string {
+		return fmt.Sprintf("%v-%v", row[0], row[1])
+	})
+	assert.Len(t, lines, 40)
+	assert.Len(t, lo.Uniq(lines), 40)
+
+	// Once the table is complete, its keys' sequences are no longer needed.
+	cp, err = LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Empty(t, cp.Tables["order_line"].PerSequences)
+}
+
+func TestGenerate_Existing(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "a",
+				Rows:       0,
+				RefColumns: []string{"id"},
+				Existing:   map[string][]any{"id": {"x", "y"}},
+				Columns:    []model.Column{{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)}},
+			},
+			{Name: "b", Rows: 20, Columns: []model.Column{{Name: "a_id", Mode: model.ColumnTypeRef, Ref: "a.id"}}},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	// Rows can reference existing rows, even if none were generated.
+	assert.Len(t, writer.rows["b"], 20)
+	for _, row := range writer.rows["b"] {
+		assert.Contains(t, []any{"x", "y"}, row[0])
+	}
+}
+
+func TestGenerate_RefQuery(t *testing.T) {
+	query := "SELECT id FROM member"
+
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name: "event",
+				Rows: 20,
+				Columns: []model.Column{
+					{Name: "member_id", Mode: model.ColumnTypeRef, RefQuery: query, RefValues: []any{"1", "2"}},
+					{Name: "other_member_id", Mode: model.ColumnTypeRef, RefQuery: query, RefValues: []any{"1", "2"}},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	assert.Len(t, writer.rows["event"], 20)
+	for _, row := range writer.rows["event"] {
+		assert.Contains(t, []any{"1", "2"}, row[0])
+		assert.Contains(t, []any{"1", "2"}, row[1])
+	}
+}
+
+func TestGenerate_SelfReference(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "employee",
+				Rows:       100,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+					{Name: "manager_id", Mode: model.ColumnTypeRef, Ref: "employee.id"},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["employee"]
+	assert.Len(t, rows, 100)
+
+	// The first row of each batch is a root, and every other row references
+	// a row that was written before it.
+	seen := map[any]bool{}
+	for i, row := range rows {
+		if i%10 == 0 {
+			assert.Nil(t, row[1], "%v isn't a root", row)
+		} else {
+			assert.True(t, seen[row[1]], "%v references a later row", row)
+		}
+		seen[row[0]] = true
+	}
+
+	managed := lo.CountBy(rows, func(row []any) bool { return row[1] != nil })
+	assert.Equal(t, 90, managed)
+}
+
+func TestGenerate_Deferred(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "department",
+				Rows:       25,
+				RefColumns: []string{"id"},
+				Existing:   map[string][]any{"id": {int64(1000)}},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+					{Name: "head_id", Mode: model.ColumnTypeRef, Ref: "employee.id", Deferred: true},
+				},
+			},
+			{
+				Name:       "employee",
+				Rows:       20,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(100)},
+					{Name: "department_id", Mode: model.ColumnTypeRef, Ref: "department.id"},
+				},
+			},
+		},
+	}
+
+	writer := &updatingWriter{recordingWriter: recordingWriter{rows: map[string][][]any{}}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	// Deferred columns are written as NULL, then filled in with references
+	// to the table that didn't exist yet.
+	for _, row := range writer.rows["department"] {
+		assert.Nil(t, row[1])
+	}
+
+	assert.Equal(t, "department.head_id", writer.updated)
+	assert.Equal(t, "id", writer.key)
+
+	// Only the rows written by this run are updated, across every batch.
+	departmentIDs := lo.Map(writer.rows["department"], func(row []any, _ int) any { return row[0] })
+	assert.ElementsMatch(t, departmentIDs, writer.keys)
+	assert.NotContains(t, writer.keys, int64(1000))
+
+	employeeIDs := lo.Map(writer.rows["employee"], func(row []any, _ int) any { return row[0] })
+	assert.Len(t, writer.values, 25)
+	for _, v := range writer.values {
+		assert.Contains(t, employeeIDs, v)
+	}
+
+	// Deferred columns reference rows from every batch, not just the last.
+	batches := lo.Uniq(lo.Map(writer.values, func(v any, _ int) int64 { return (v.(int64) - 100) / 10 }))
+	assert.ElementsMatch(t, []int64{0, 1}, batches)
+}
+
+func TestGenerate_Tree(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name: "category",
+				Rows: 50,
+				Tree: &model.Tree{
+					Key:           "id",
+					Parent:        "parent_id",
+					MaxDepth:      3,
+					Children:      model.IntRange{Min: 1, Max: 4},
+					Path:          "path",
+					PathSeparator: ".",
+					Depth:         "depth",
+				},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+					{Name: "parent_id", Mode: model.ColumnTypeTree},
+					{Name: "path", Mode: model.ColumnTypeTree},
+					{Name: "depth", Mode: model.ColumnTypeTree},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 4, 4, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["category"]
+	assert.Len(t, rows, 50)
+	assert.Equal(t, 50, sut.generated["category"])
+
+	written := map[any][]any{}
+	for _, row := range rows {
+		id, parent, path, depth := row[0], row[1], row[2], row[3]
+
+		if parent == nil {
+			assert.Equal(t, fmt.Sprint(id), path)
+			assert.Equal(t, int64(1), depth)
+		} else {
+			// Parents are written before their children.
+			p, ok := written[parent]
+			assert.True(t, ok)
+			assert.Equal(t, fmt.Sprintf("%s.%d", p[2], id), path)
+			assert.Equal(t, p[3].(int64)+1, depth)
+		}
+
+		assert.LessOrEqual(t, depth, int64(3))
+		written[id] = row
+	}
+}
+
+func TestGenerate_TreeConflict(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "category",
+				Rows:       20,
+				RefColumns: []string{"id"},
+				Tree:       &model.Tree{Key: "id", Parent: "parent_id", Children: model.IntRange{Min: 1, Max: 3}},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+					{Name: "parent_id", Mode: model.ColumnTypeTree},
+				},
+			},
+		},
+	}
+
+	writer := &conflictingWriter{
+		recordingWriter: recordingWriter{rows: map[string][][]any{}},
+		table:           "category",
+		existing:        map[string]bool{"1": true},
+	}
+
+	// The skipped root's children can't be written without it.
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 10, 1, RetryPolicy{TopUps: 10}, CheckpointPolicy{})
+	assert.ErrorContains(t, sut.Generate(context.Background()), "1 rows weren't written, and can't be replaced in a tree")
+	assert.Len(t, writer.rows["category"], 9)
+}
+
+func TestGenerate_Edges(t *testing.T) {
+	cases := []struct {
+		name         string
+		rows         int
+		distribution string
+		selfEdges    bool
+		expError     bool
+	}{
+		{name: "uniform", rows: 300, distribution: model.EdgeDistributionUniform},
+		{name: "power law", rows: 300, distribution: model.EdgeDistributionPowerLaw},
+		{name: "every edge", rows: 380, distribution: model.EdgeDistributionPowerLaw},
+		{name: "every edge with self edges", rows: 400, distribution: model.EdgeDistributionUniform, selfEdges: true},
+		{name: "too many edges", rows: 381, distribution: model.EdgeDistributionUniform, expError: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := model.Config{
+				Tables: []model.Table{
+					{
+						Name:       "person",
+						Rows:       20,
+						RefColumns: []string{"id"},
+						Columns: []model.Column{
+							{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+						},
+					},
+					{
+						Name: "follows",
+						Rows: c.rows,
+						Edges: &model.Edges{
+							From:         "follower_id",
+							To:           "followee_id",
+							SelfEdges:    c.selfEdges,
+							Distribution: c.distribution,
+							Exponent:     1,
+						},
+						Columns: []model.Column{
+							{Name: "follower_id", Mode: model.ColumnTypeRef, Ref: "person.id"},
+							{Name: "followee_id", Mode: model.ColumnTypeRef, Ref: "person.id"},
+						},
+					},
+				},
+			}
+
+			writer := &recordingWriter{rows: map[string][][]any{}}
+
+			// The person table is generated in several batches, every one of
+			// which can be referenced.
+			sut := NewDataGenerator(writer, test.NewNilLogger(), config, 4, 4, 5, 1, RetryPolicy{}, CheckpointPolicy{})
+			err := sut.Generate(context.Background())
+			if c.expError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			rows := writer.rows["follows"]
+			assert.Len(t, rows, c.rows)
+
+			seen := map[string]bool{}
+			for _, row := range rows {
+				key := fmt.Sprintf("%v-%v", row[0], row[1])
+				assert.False(t, seen[key], "duplicate edge %s", key)
+				seen[key] = true
+
+				if !c.selfEdges {
+					assert.NotEqual(t, row[0], row[1])
+				}
+			}
+		})
+	}
+}
+
+func TestPowerLawCDF(t *testing.T) {
+	cdf := powerLawCDF(4, 1)
+	assert.InDeltaSlice(t, []float64{1, 1.5, 1.8333, 2.0833}, cdf, 0.0001)
+
+	// Lower ranked values are picked more often.
+	p := edgeEndpoint{values: make([]any, 100), cdf: powerLawCDF(100, 1)}
+
+	counts := make([]int, 100)
+	for i := 0; i < 10000; i++ {
+		counts[p.sample()]++
+	}
+	assert.Greater(t, counts[0], counts[9])
+	assert.Greater(t, counts[9], counts[99])
+}
+
+func TestGenerate_Conflict(t *testing.T) {
+	cases := []struct {
+		name      string
+		rows      int
+		existing  []int
+		ambiguous int
+		expIDs    []string
+		expError  string
+	}{
+		{
+			name:     "topped up",
+			rows:     5,
+			existing: []int{2, 4},
+			expIDs:   []string{"1", "3", "5", "6", "7"},
+		},
+		{
+			name:     "unable to top up",
+			rows:     5,
+			existing: lo.Range(100),
+			expError: "5 rows weren't written after topping up 10 times",
+		},
+		{
+			name:      "ambiguous result",
+			rows:      10,
+			ambiguous: 1,
+			expIDs:    []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config := model.Config{
+				Tables: []model.Table{
+					{
+						Name:       "paint",
+						Rows:       c.rows,
+						RefColumns: []string{"id"},
+						Columns: []model.Column{
+							{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+						},
+					},
+					{
+						Name: "tin",
+						Rows: 20,
+						Columns: []model.Column{
+							{Name: "paint_id", Mode: model.ColumnTypeRef, Ref: "paint.id"},
+						},
+					},
+				},
+			}
+
+			writer := &conflictingWriter{
+				recordingWriter: recordingWriter{rows: map[string][][]any{}},
+				table:           "paint",
+				existing:        lo.SliceToMap(c.existing, func(id int) (string, bool) { return fmt.Sprint(id), true }),
+				ambiguous:       c.ambiguous,
+			}
+
+			retry := RetryPolicy{Attempts: 2, TopUps: 10}
+			sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 1, 5, 1, retry, CheckpointPolicy{})
+
+			err := sut.Generate(context.Background())
+			if c.expError != "" {
+				assert.ErrorContains(t, err, c.expError)
+				return
+			}
+			assert.NoError(t, err)
+
+			ids := lo.Map(writer.rows["paint"], func(row []any, _ int) string { return fmt.Sprint(row[0]) })
+			assert.Equal(t, c.expIDs, ids)
+			assert.Equal(t, c.rows, sut.generated["paint"])
+
+			// Only the paints that were known to be written are referenced.
+			referenced := lo.Uniq(lo.Map(writer.rows["tin"], func(row []any, _ int) string { return fmt.Sprint(row[0]) }))
+			assert.Subset(t, c.expIDs[c.ambiguous*5:], referenced)
+		})
+	}
+}
+
+func TestGenerate_IncPer(t *testing.T) {
+	lineNumber, err := model.NewSequence(1, model.IncProps{Per: "purchase_id"})
+	assert.NoError(t, err)
+
+	reference, err := model.NewSequence(1, model.IncProps{Format: "LN-%04d"})
+	assert.NoError(t, err)
+
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "purchase",
+				Rows:       5,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+				},
+			},
+			{
+				Name: "purchase_line",
+				Rows: 100,
+				Columns: []model.Column{
+					{Name: "line_number", Mode: model.ColumnTypeInc, NextID: lineNumber},
+					{Name: "purchase_id", Mode: model.ColumnTypeRef, Ref: "purchase.id"},
+					{Name: "reference", Mode: model.ColumnTypeInc, NextID: reference},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 4, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["purchase_line"]
+	assert.Len(t, rows, 100)
+
+	// Each purchase's lines are numbered from 1, without gaps.
+	lines := lo.GroupBy(rows, func(row []any) any { return row[1] })
+	for _, purchaseLines := range lines {
+		numbers := lo.Map(purchaseLines, func(row []any, _ int) int64 { return row[0].(int64) })
+		slices.Sort(numbers)
+		assert.Equal(t, lo.RangeFrom(int64(1), len(numbers)), numbers)
+	}
+
+	references := lo.Map(rows, func(row []any, _ int) any { return row[2] })
+	assert.Contains(t, references, "LN-0001")
+	assert.Contains(t, references, "LN-0100")
+}
+
+func TestGenerate_Series(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	min, max := 0.0, 100.0
+
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "device",
+				Rows:       5,
+				Workers:    1,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+				},
+			},
+			{
+				Name: "reading",
+				Rows: 500,
+				Series: &model.Series{
+					Timestamp: "recorded_at",
+					Start:     start,
+					Interval:  time.Minute,
+					Jitter:    10 * time.Second,
+					Format:    time.RFC3339,
+					Per:       "device_id",
+					Values: []model.SeriesValue{
+						{Column: "temperature", Start: 20, Walk: 0.5, Min: &min, Max: &max},
+						{Column: "load", Start: 50, Trend: 1},
+					},
+				},
+				Columns: []model.Column{
+					{Name: "device_id", Mode: model.ColumnTypeRef, Ref: "device.id"},
+					{Name: "recorded_at", Mode: model.ColumnTypeSeries},
+					{Name: "temperature", Mode: model.ColumnTypeSeries},
+					{Name: "load", Mode: model.ColumnTypeSeries},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 4, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["reading"]
+	assert.Len(t, rows, 500)
+
+	for _, readings := range lo.GroupBy(rows, func(row []any) any { return row[0] }) {
+		times := lo.Map(readings, func(row []any, _ int) time.Time {
+			ts, err := time.Parse(time.RFC3339, row[1].(string))
+			assert.NoError(t, err)
+			return ts
+		})
+
+		// Each device's readings are an interval apart, plus jitter.
+		slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+		for i, ts := range times {
+			due := start.Add(time.Duration(i) * time.Minute)
+			assert.False(t, ts.Before(due))
+			assert.True(t, ts.Before(due.Add(10*time.Second)))
+		}
+
+		// Walks change gradually, and stay within their bounds, while trends
+		// change by the same amount each reading.
+		temperatures := lo.Map(readings, func(row []any, _ int) float64 { return row[2].(float64) })
+		loads := lo.Map(readings, func(row []any, _ int) float64 { return row[3].(float64) })
+		slices.Sort(temperatures)
+		slices.Sort(loads)
+
+		assert.GreaterOrEqual(t, temperatures[0], min)
+		assert.LessOrEqual(t, temperatures[len(temperatures)-1], max)
+		assert.Equal(t, lo.Map(lo.Range(len(loads)), func(i int, _ int) float64 { return 50 + float64(i) }), loads)
+	}
+}
+
+func TestGenerate_ResumeSeries(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newConfig := func() model.Config {
+		return model.Config{
+			Tables: []model.Table{
+				{
+					Name: "reading",
+					Rows: 40,
+					Series: &model.Series{
+						Timestamp: "recorded_at",
+						Start:     start,
+						Interval:  time.Minute,
+						Format:    time.RFC3339,
+						Per:       "device_id",
+						Values:    []model.SeriesValue{{Column: "level", Start: 50, Walk: 1}},
+					},
+					Columns: []model.Column{
+						{Name: "device_id", Mode: model.ColumnTypeSet, Set: []string{"a", "b"}},
+						{Name: "recorded_at", Mode: model.ColumnTypeSeries},
+						{Name: "level", Mode: model.ColumnTypeSeries},
+					},
+				},
+			},
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	policy := CheckpointPolicy{Path: path}
+
+	// Interrupt the first run after its first batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			cancel()
+			return nil
+		},
+	}
+
+	sut := NewDataGenerator(first, test.NewNilLogger(), newConfig(), 1, 1, 10, 1, RetryPolicy{}, policy)
+	assert.ErrorIs(t, sut.Generate(ctx), context.Canceled)
+
+	cp, err := LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cp.Tables["reading"].Series)
+
+	policy.Resume = &cp
+	second := &recordingWriter{rows: map[string][][]any{}}
+
+	sut = NewDataGenerator(second, test.NewNilLogger(), newConfig(), 1, 1, 10, 1, RetryPolicy{}, policy)
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	// Each device's readings continue from where the first run left off.
+	readings := lo.Map(append(first.rows["reading"], second.rows["reading"]...), func(row []any, _ int) string {
+		return fmt.Sprintf("%v@%v", row[0], row[1])
+	})
+	assert.Len(t, readings, 40)
+	assert.Len(t, lo.Uniq(readings), 40)
+
+	// Once the table is complete, its series' positions are no longer
+	// needed.
+	cp, err = LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Empty(t, cp.Tables["reading"].Series)
+}
+
+func TestSeriesPosition(t *testing.T) {
+	min, max := 0.0, 100.0
+
+	series := model.Series{
+		Timestamp: "recorded_at",
+		Start:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Interval:  time.Minute,
+		Jitter:    30 * time.Second,
+		Format:    time.RFC3339,
+		Values: []model.SeriesValue{
+			{Column: "level", Start: 95, Walk: 5, Min: &min, Max: &max},
+			{Column: "load", Start: 50, Trend: 1, Amplitude: 10, Period: time.Hour},
+		},
+	}
+
+	table := model.Table{
+		Series: &series,
+		Columns: []model.Column{
+			{Name: "recorded_at", Mode: model.ColumnTypeSeries},
+			{Name: "level", Mode: model.ColumnTypeSeries},
+			{Name: "load", Mode: model.ColumnTypeSeries},
+		},
+	}
+
+	state := newSeriesState(table)
+	row := make([]any, 3)
+	for i := 0; i < 25; i++ {
+		state.fill(row)
+	}
+
+	// A series' position can be recovered from its last reading.
+	last, err := time.Parse(time.RFC3339, row[0].(string))
+	assert.NoError(t, err)
+
+	level, load := row[1].(float64), row[2].(float64)
+	act := seriesPosition(series, last, []*float64{&level, &load})
+	exp := state.positions()[""]
+
+	assert.Equal(t, exp.Readings, act.Readings)
+	assert.InDeltaSlice(t, exp.Walks, act.Walks, 1e-9)
+
+	// Missing values have no walk.
+	act = seriesPosition(series, last, []*float64{nil, &load})
+	assert.Equal(t, 0.0, act.Walks[0])
+}
+
+func TestGenerate_Cumulative(t *testing.T) {
+	amount, err := model.NewRawMessage(model.IntRange{Min: -100, Max: 100})
+	assert.NoError(t, err)
+
+	version, err := model.NewSequence(1, model.IncProps{Per: "account_id"})
+	assert.NoError(t, err)
+
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "account",
+				Rows:       5,
+				Workers:    1,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+				},
+			},
+			{
+				Name: "ledger",
+				Rows: 500,
+				Columns: []model.Column{
+					{Name: "account_id", Mode: model.ColumnTypeRef, Ref: "account.id"},
+					{Name: "version", Mode: model.ColumnTypeInc, NextID: version},
+					{Name: "amount", Mode: model.ColumnTypeRange, Range: "int", Props: amount},
+					{Name: "balance", Mode: model.ColumnTypeCumulative, Cumulative: "amount", Total: model.NewRunningTotal(model.CumulativeProps{Start: 1000, Per: "account_id"})},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 1, 4, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["ledger"]
+	assert.Len(t, rows, 500)
+
+	// In version order, each account's balance is its opening balance plus
+	// every amount up to and including the row's own.
+	for _, entries := range lo.GroupBy(rows, func(row []any) any { return row[0] }) {
+		slices.SortFunc(entries, func(a, b []any) int { return int(a[1].(int64) - b[1].(int64)) })
+
+		balance := int64(1000)
+		for _, entry := range entries {
+			balance += entry[2].(int64)
+			assert.Equal(t, balance, entry[3])
+		}
+	}
+}
+
+func TestGenerate_ResumeCumulative(t *testing.T) {
+	amount, err := model.NewRawMessage(model.IntRange{Min: 1, Max: 100})
+	assert.NoError(t, err)
+
+	newConfig := func() model.Config {
+		return model.Config{
+			Tables: []model.Table{
+				{
+					Name: "ledger",
+					Rows: 40,
+					Columns: []model.Column{
+						{Name: "account_id", Mode: model.ColumnTypeSet, Set: []string{"a", "b"}},
+						{Name: "version", Mode: model.ColumnTypeInc, NextID: lo.Must(model.NewSequence(1, model.IncProps{Per: "account_id"}))},
+						{Name: "amount", Mode: model.ColumnTypeRange, Range: "int", Props: amount},
+						{Name: "balance", Mode: model.ColumnTypeCumulative, Cumulative: "amount", Total: model.NewRunningTotal(model.CumulativeProps{Per: "account_id"})},
+					},
+				},
+			},
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	policy := CheckpointPolicy{Path: path}
+
+	// Interrupt the first run after its first batch, once later batches
+	// have been queued, so that their amounts have been totalled but not
+	// written.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first := &recordingWriter{
+		rows: map[string][][]any{},
+		before: func(table string) error {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+			return nil
+		},
+	}
+
+	sut := NewDataGenerator(first, test.NewNilLogger(), newConfig(), 1, 1, 10, 4, RetryPolicy{}, policy)
+	assert.ErrorIs(t, sut.Generate(ctx), context.Canceled)
+
+	cp, err := LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cp.Tables["ledger"].Totals["balance"])
+
+	policy.Resume = &cp
+	second := &recordingWriter{rows: map[string][][]any{}}
+
+	sut = NewDataGenerator(second, test.NewNilLogger(), newConfig(), 1, 1, 10, 4, RetryPolicy{}, policy)
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := append(first.rows["ledger"], second.rows["ledger"]...)
+	assert.Len(t, rows, 40)
+
+	// Balances continue from the written rows, rather than from rows that
+	// were generated but never written.
+	for _, entries := range lo.GroupBy(rows, func(row []any) any { return row[0] }) {
+		slices.SortFunc(entries, func(a, b []any) int { return int(a[1].(int64) - b[1].(int64)) })
+
+		var balance int64
+		for _, entry := range entries {
+			balance += entry[2].(int64)
+			assert.Equal(t, balance, entry[3])
+		}
+	}
+
+	cp, err = LoadCheckpoint(path)
+	assert.NoError(t, err)
+	assert.Empty(t, cp.Tables["ledger"].Totals)
+}
+
+func TestGenerate_CyclicDependency(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{Name: "a", Rows: 1, Columns: []model.Column{{Name: "b_id", Mode: model.ColumnTypeRef, Ref: "b.id"}}},
+			{Name: "b", Rows: 1, Columns: []model.Column{{Name: "a_id", Mode: model.ColumnTypeRef, Ref: "a.id"}}},
+		},
+	}
+
+	sut := NewDataGenerator(&recordingWriter{}, test.NewNilLogger(), config, 1, 1, 1, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.Error(t, sut.Generate(context.Background()))
+}
+
 func TestGenerateRow(t *testing.T) {
 	cases := []struct {
 		name       string
@@ -159,3 +1269,100 @@ func TestGenerateRow(t *testing.T) {
 		})
 	}
 }
+
+// recordingWriter is an output.Writer that keeps every row in memory,
+// along with the order in which tables were written to.
+type recordingWriter struct {
+	mu    sync.Mutex
+	rows  map[string][][]any
+	order []string
+
+	// before is an optional hook that runs before each write.
+	before func(table string) error
+}
+
+func (w *recordingWriter) Write(ctx context.Context, table model.Table, rows [][]any) error {
+	if w.before != nil {
+		if err := w.before(table.Name); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.rows[table.Name] = append(w.rows[table.Name], rows...)
+	w.order = append(w.order, table.Name)
+	return nil
+}
+
+func (w *recordingWriter) Close() error {
+	return nil
+}
+
+// conflictingWriter skips rows of a table whose id already exists, as a
+// database would under the conflict insert mode. Its first ambiguous
+// writes are written, but fail with an ambiguous result, so that retrying
+// them conflicts.
+type conflictingWriter struct {
+	recordingWriter
+	table     string
+	existing  map[string]bool
+	ambiguous int
+}
+
+func (w *conflictingWriter) WriteReturning(ctx context.Context, table model.Table, rows [][]any, columns []string) ([][]any, error) {
+	w.mu.Lock()
+	var written [][]any
+	for _, row := range rows {
+		if table.Name == w.table {
+			if w.existing[fmt.Sprint(row[0])] {
+				continue
+			}
+			w.existing[fmt.Sprint(row[0])] = true
+		}
+		written = append(written, row)
+	}
+
+	ambiguous := table.Name == w.table && w.ambiguous > 0
+	if ambiguous {
+		w.ambiguous--
+	}
+	w.mu.Unlock()
+
+	if err := w.Write(ctx, table, written); err != nil {
+		return nil, err
+	}
+
+	if ambiguous {
+		return nil, &pgconn.PgError{Code: "40003"}
+	}
+
+	names := lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name })
+	return lo.Map(written, func(row []any, _ int) []any {
+		return lo.Map(columns, func(column string, _ int) any { return fmt.Sprint(row[lo.IndexOf(names, column)]) })
+	}), nil
+}
+
+// updatingWriter is a recordingWriter that records the values of a single
+// deferred column update.
+type updatingWriter struct {
+	recordingWriter
+
+	updated string
+	key     string
+	keys    []any
+	values  []any
+}
+
+func (w *updatingWriter) Update(ctx context.Context, table model.Table, column, key string, keys []any, batch int, value func() any) (int, error) {
+	w.updated = table.Name + "." + column
+	w.key = key
+	w.keys = keys
+
+	for range keys {
+		w.values = append(w.values, value())
+	}
+
+	return len(w.values), nil
+}
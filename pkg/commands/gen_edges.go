@@ -0,0 +1,169 @@
+package commands
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/samber/lo"
+)
+
+// maxEdgeAttempts is the number of times an edge is sampled before falling
+// back to searching for one that hasn't been used, which only happens once
+// most of the possible edges have been generated.
+const maxEdgeAttempts = 100
+
+// edgeSampler picks unique edges between the values of two referenced
+// columns. It is safe for concurrent use.
+type edgeSampler struct {
+	edges    model.Edges
+	from, to edgeEndpoint
+	seen     *uniqueSet
+}
+
+// edgeEndpoint holds the values that one end of an edge can take.
+type edgeEndpoint struct {
+	// index is the position of the endpoint's column in the table.
+	index int
+
+	values []any
+	keys   []string
+
+	// cdf holds the cumulative weight of each value for power law
+	// distributions, and is nil for uniform distributions.
+	cdf []float64
+}
+
+// newEdgeSampler returns an edgeSampler for a table's edges, failing if
+// the referenced columns don't have enough values for rows unique edges.
+func newEdgeSampler(table model.Table, data *model.IterationData, rows int) (*edgeSampler, error) {
+	e := *table.Edges
+
+	from, err := newEdgeEndpoint(table, e.From, data, e)
+	if err != nil {
+		return nil, fmt.Errorf("preparing %q values: %w", e.From, err)
+	}
+
+	to, err := newEdgeEndpoint(table, e.To, data, e)
+	if err != nil {
+		return nil, fmt.Errorf("preparing %q values: %w", e.To, err)
+	}
+
+	// Self-edges are only possible between values that both ends share.
+	capacity := len(from.keys) * len(to.keys)
+	if !e.SelfEdges {
+		capacity -= len(lo.Intersect(from.keys, to.keys))
+	}
+
+	if rows > capacity {
+		return nil, fmt.Errorf("%d rows requested, but only %d unique edges are possible", rows, capacity)
+	}
+
+	return &edgeSampler{
+		edges: e,
+		from:  from,
+		to:    to,
+		seen:  newUniqueSet(),
+	}, nil
+}
+
+func newEdgeEndpoint(table model.Table, column string, data *model.IterationData, e model.Edges) (edgeEndpoint, error) {
+	c, index, ok := lo.FindIndexOf(table.Columns, func(c model.Column) bool {
+		return c.Name == column
+	})
+
+	if !ok {
+		return edgeEndpoint{}, fmt.Errorf("missing column")
+	}
+
+	if c.Deferred {
+		return edgeEndpoint{}, fmt.Errorf("edge columns can't be deferred")
+	}
+
+	// Values can appear in both the seeded and generated values, so
+	// duplicates are removed to avoid generating the same edge twice.
+	values := lo.UniqBy(data.GetAllValues(c.RefKey()), model.FormatValue)
+	if len(values) == 0 {
+		return edgeEndpoint{}, fmt.Errorf("no values to reference")
+	}
+
+	// Values are shuffled, so that the most popular values in a power law
+	// distribution differ between each end of the edge.
+	random.Shuffle(values)
+
+	p := edgeEndpoint{
+		index:  index,
+		values: values,
+		keys:   lo.Map(values, func(v any, _ int) string { return model.FormatValue(v) }),
+	}
+
+	if e.Distribution == model.EdgeDistributionPowerLaw {
+		p.cdf = powerLawCDF(len(values), e.Exponent)
+	}
+
+	return p, nil
+}
+
+// powerLawCDF returns the cumulative weights of n values, where the value
+// at rank k (starting from 1) has a weight of 1/k^exponent.
+func powerLawCDF(n int, exponent float64) []float64 {
+	cdf := make([]float64, n)
+
+	var total float64
+	for i := range cdf {
+		total += 1 / math.Pow(float64(i+1), exponent)
+		cdf[i] = total
+	}
+
+	return cdf
+}
+
+// sample returns the index of a random value.
+func (p edgeEndpoint) sample() int {
+	if p.cdf == nil {
+		return int(random.Int(0, int64(len(p.values))))
+	}
+
+	i := sort.SearchFloat64s(p.cdf, random.Float(0, p.cdf[len(p.cdf)-1]))
+	return min(i, len(p.cdf)-1)
+}
+
+// fill sets the endpoints of an edge that hasn't been generated yet.
+func (s *edgeSampler) fill(row []any) error {
+	for attempt := 0; attempt < maxEdgeAttempts; attempt++ {
+		if i, j := s.from.sample(), s.to.sample(); s.use(i, j) {
+			row[s.from.index], row[s.to.index] = s.from.values[i], s.to.values[j]
+			return nil
+		}
+	}
+
+	// Most edges have been used, so search for one that hasn't, starting
+	// from a random value at each end.
+	fromStart, toStart := s.from.sample(), s.to.sample()
+	for di := range s.from.values {
+		i := (fromStart + di) % len(s.from.values)
+
+		for dj := range s.to.values {
+			j := (toStart + dj) % len(s.to.values)
+
+			if s.use(i, j) {
+				row[s.from.index], row[s.to.index] = s.from.values[i], s.to.values[j]
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("no unique edges left")
+}
+
+// use records an edge as generated, returning false if it can't be used.
+func (s *edgeSampler) use(i, j int) bool {
+	from, to := s.from.keys[i], s.to.keys[j]
+	if !s.edges.SelfEdges && from == to {
+		return false
+	}
+
+	return s.seen.add(from + "\x00" + to)
+}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/samber/lo"
+)
+
+// seriesState holds the position of each of a table's series, so that
+// every worker generating the table continues the same series.
+type seriesState struct {
+	series    model.Series
+	timestamp int
+	per       int
+	values    []int
+
+	mu       sync.Mutex
+	entities map[string]*seriesEntity
+}
+
+// seriesEntity is the position of a single series, and the current offset
+// of each of its random walks.
+type seriesEntity struct {
+	readings int64
+	walks    []float64
+}
+
+// newSeriesState returns the state of a table's series, or nil if it
+// isn't a series table. Series that earlier rows have started continue
+// from where they got to.
+func newSeriesState(table model.Table) *seriesState {
+	if table.Series == nil {
+		return nil
+	}
+
+	names := lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name })
+	s := *table.Series
+
+	entities := make(map[string]*seriesEntity, len(s.Existing))
+	for key, p := range s.Existing {
+		walks := make([]float64, len(s.Values))
+		copy(walks, p.Walks)
+		entities[key] = &seriesEntity{readings: p.Readings, walks: walks}
+	}
+
+	return &seriesState{
+		series:    s,
+		timestamp: lo.IndexOf(names, s.Timestamp),
+		per:       lo.IndexOf(names, s.Per),
+		values:    lo.Map(s.Values, func(v model.SeriesValue, _ int) int { return lo.IndexOf(names, v.Column) }),
+		entities:  entities,
+	}
+}
+
+// positions returns the position of each series that has been started.
+func (s *seriesState) positions() map[string]model.SeriesPosition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions := make(map[string]model.SeriesPosition, len(s.entities))
+	for key, e := range s.entities {
+		positions[key] = model.SeriesPosition{Readings: e.readings, Walks: slices.Clone(e.walks)}
+	}
+
+	return positions
+}
+
+// seriesPosition returns the position of a series whose last reading was
+// taken at the given time, with the given values (nil for NULL). Jitter
+// is less than the interval, so the reading's number is the number of
+// whole intervals since the start, and each walk's offset is whatever the
+// value's start, trend, and cycle don't account for (including any offset
+// from holding it at a bound).
+func seriesPosition(s model.Series, last time.Time, values []*float64) model.SeriesPosition {
+	n := max(int64(last.Sub(s.Start)/s.Interval), 0)
+	elapsed := time.Duration(n) * s.Interval
+
+	walks := make([]float64, len(s.Values))
+	for i, v := range s.Values {
+		if values[i] != nil {
+			walks[i] = *values[i] - baseline(v, n, elapsed)
+		}
+	}
+
+	return model.SeriesPosition{Readings: n + 1, Walks: walks}
+}
+
+// baseline returns a value's nth reading, before its random walk.
+func baseline(v model.SeriesValue, n int64, elapsed time.Duration) float64 {
+	x := v.Start + v.Trend*float64(n)
+	if v.Amplitude != 0 {
+		x += v.Amplitude * math.Sin(2*math.Pi*float64(elapsed)/float64(v.Period))
+	}
+
+	return x
+}
+
+// fill sets a row's series columns to the next reading in its series,
+// which is the series of the row's per column value, if the series has
+// one.
+func (s *seriesState) fill(row []any) {
+	var key string
+	if s.per != -1 {
+		key = model.FormatValue(row[s.per])
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entities[key]
+	if !ok {
+		e = &seriesEntity{walks: make([]float64, len(s.series.Values))}
+		s.entities[key] = e
+	}
+
+	n := e.readings
+	e.readings++
+
+	// Jitter is less than the interval, so readings never overtake the
+	// next reading's time.
+	elapsed := time.Duration(n) * s.series.Interval
+	row[s.timestamp] = s.series.Start.Add(elapsed + random.Interval(0, s.series.Jitter)).Format(s.series.Format)
+
+	for i, v := range s.series.Values {
+		if n > 0 && v.Walk > 0 {
+			e.walks[i] += random.Float(-v.Walk, v.Walk)
+		}
+
+		x := baseline(v, n, elapsed) + e.walks[i]
+
+		// Walks are held at the bounds, rather than wandering beyond them,
+		// so that values move away from a bound as soon as they can.
+		clamped := v.Clamp(x)
+		e.walks[i] += clamped - x
+		row[s.values[i]] = clamped
+	}
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/samber/lo"
+)
+
+// generateTreeTable generates a table's rows as a forest of trees. As the
+// computed columns of each row depend on the shape of the whole tree, every
+// row is generated before any are written. Rows are written sequentially,
+// in breadth-first order, so that parents are always written before their
+// children.
+func (g *DataGenerator) generateTreeTable(ctx context.Context, table model.Table, iter iteration, data *model.IterationData) error {
+	rows, err := g.generateTree(table, data, iter.rows())
+	if err != nil {
+		return fmt.Errorf("generating table %q: %w", table.Name, err)
+	}
+
+	for _, batch := range lo.Chunk(rows, g.batchFor(table)) {
+		if err = ctx.Err(); err != nil {
+			return fmt.Errorf("generating table %q: %w", table.Name, err)
+		}
+
+		written, err := g.writeRows(ctx, table, data, batch)
+		if err != nil {
+			return fmt.Errorf("generating table %q: writing rows: %w", table.Name, err)
+		}
+
+		if err = g.recordProgress(table, data, written, 1, 0); err != nil {
+			return fmt.Errorf("generating table %q: recording progress: %w", table.Name, err)
+		}
+
+		// Rows can't be added to a tree once it's been generated, and the
+		// children of rows that weren't written would reference rows that
+		// don't exist.
+		if written < len(batch) {
+			return fmt.Errorf("generating table %q: %d rows weren't written, and can't be replaced in a tree", table.Name, len(batch)-written)
+		}
+	}
+
+	g.logger.Info().Str("table", table.Name).Msg("finished table")
+	return nil
+}
+
+// generateTree generates n rows and fills in their tree columns.
+func (g *DataGenerator) generateTree(table model.Table, data *model.IterationData, n int) ([][]any, error) {
+	tree := *table.Tree
+	nodes := model.BuildTree(tree, n)
+
+	constraints, err := newRowConstraints(table, data, n)
+	if err != nil {
+		return nil, fmt.Errorf("preparing constraints: %w", err)
+	}
+
+	rows, err := g.generateRows(table, data, constraints, n)
+	if err != nil {
+		return nil, fmt.Errorf("generating rows: %w", err)
+	}
+
+	names := lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name })
+	key := lo.IndexOf(names, tree.Key)
+	paths := make([]string, len(nodes))
+
+	set := func(row []any, column string, value any) {
+		if i := lo.IndexOf(names, column); column != "" && i != -1 {
+			row[i] = value
+		}
+	}
+
+	for i, node := range nodes {
+		row := rows[i]
+
+		paths[i] = model.FormatValue(row[key])
+		if node.Parent != -1 {
+			paths[i] = paths[node.Parent] + tree.PathSeparator + paths[i]
+			set(row, tree.Parent, rows[node.Parent][key])
+		}
+
+		set(row, tree.Path, paths[i])
+		set(row, tree.Depth, int64(node.Depth))
+		set(row, tree.Left, node.Left)
+		set(row, tree.Right, node.Right)
+
+		// Sequences and totals may be per, and columns derived from, the
+		// tree's columns.
+		if err = deriveSequences(table, row); err != nil {
+			return nil, fmt.Errorf("numbering row: %w", err)
+		}
+
+		if err = deriveTotals(table, row); err != nil {
+			return nil, fmt.Errorf("totalling row: %w", err)
+		}
+
+		if err = deriveValues(table, row); err != nil {
+			return nil, fmt.Errorf("deriving values: %w", err)
+		}
+	}
+
+	return rows, nil
+}
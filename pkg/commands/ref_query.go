@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/query"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// LoadRefQueries runs the query of every ref_query column in the config,
+// storing the values it returns so that rows can reference them. Columns
+// that share a query only run it once.
+func LoadRefQueries(ctx context.Context, db *pgxpool.Pool, config model.Config, logger zerolog.Logger) error {
+	results := map[string][]any{}
+
+	for _, table := range config.Tables {
+		for i, c := range table.Columns {
+			if c.RefQuery == "" {
+				continue
+			}
+
+			values, ok := results[c.RefQuery]
+			if !ok {
+				var err error
+				if values, err = streamValues(ctx, db, c.RefQuery); err != nil {
+					return fmt.Errorf("running ref_query for %s.%s: %w", table.Name, c.Name, err)
+				}
+				results[c.RefQuery] = values
+			}
+
+			table.Columns[i].RefValues = values
+
+			event := logger.Info()
+			if len(values) == 0 {
+				event = logger.Warn()
+			}
+			event.
+				Str("table", table.Name).
+				Str("column", c.Name).
+				Int("values", len(values)).
+				Msg("loaded ref_query values")
+		}
+	}
+
+	return nil
+}
+
+// HasRefQueries returns true if any of the config's columns are ref_query
+// columns, which need to read from a database before generation starts.
+func HasRefQueries(config model.Config) bool {
+	for _, table := range config.Tables {
+		for _, c := range table.Columns {
+			if c.RefQuery != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamValues reads the values of a query row by row, rather than
+// buffering the whole result before converting it.
+func streamValues(ctx context.Context, db *pgxpool.Pool, q string) ([]any, error) {
+	rows, err := db.Query(ctx, query.BuildRefQuery(q))
+	if err != nil {
+		return nil, fmt.Errorf("querying values: %w", err)
+	}
+	defer rows.Close()
+
+	var values []any
+	for rows.Next() {
+		var v string
+		if err = rows.Scan(&v); err != nil {
+			return nil, fmt.Errorf("scanning value: %w", err)
+		}
+		values = append(values, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading values: %w", err)
+	}
+
+	return values, nil
+}
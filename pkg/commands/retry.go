@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// RetryPolicy determines how many times, and how often, a failed write is
+// retried before giving up.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times a write is attempted.
+	Attempts int
+
+	// Backoff is the delay before the first retry, which doubles after
+	// each subsequent failure, up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// TopUps is the maximum number of times a batch is topped up with new
+	// rows, to replace rows that weren't written.
+	TopUps int
+}
+
+// delay returns a randomised delay for a given retry (starting at 1),
+// using "full jitter" to avoid retries from many writers lining up.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	// Fall back to the maximum if doubling has overflowed.
+	d := p.Backoff << (retry - 1)
+	if d <= 0 || d>>(retry-1) != p.Backoff || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if d <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// retry runs fn until it succeeds, fails with an error that isn't
+// retryable, or runs out of attempts. onRetry is called before each retry.
+func (p RetryPolicy) retry(ctx context.Context, fn func() error, onRetry func(attempt int, err error)) error {
+	attempts := max(p.Attempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryable(err) {
+			return fmt.Errorf("non-retryable error: %w", err)
+		}
+
+		if attempt >= attempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		onRetry(attempt, err)
+
+		select {
+		case <-time.After(p.delay(attempt)):
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled while retrying: %w", err)
+		}
+	}
+}
+
+// isRetryable returns true for errors that are likely to succeed if the
+// same statement is run again. These are serialization failures, results
+// whose outcome is unknown, and lost or refused connections.
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "40001": // serialization_failure
+			return true
+		case pgErr.Code == "40003": // statement_completion_unknown (ambiguous result)
+			return true
+		case pgErr.Code == "57P01": // admin_shutdown
+			return true
+		case strings.HasPrefix(pgErr.Code, "08"): // connection_exception
+			return true
+		default:
+			return false
+		}
+	}
+
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr *net.OpError
+	return errors.As(err, &netErr)
+}
+
+// isAmbiguous returns true for retryable errors after which the statement
+// may or may not have been committed, as opposed to errors that mean it
+// definitely wasn't.
+func isAmbiguous(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "40003" || strings.HasPrefix(pgErr.Code, "08")
+	}
+
+	// Statements that were never sent, or whose connection was never
+	// established, can't have been committed.
+	if pgconn.SafeToRetry(err) {
+		return false
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return false
+	}
+
+	return isRetryable(err)
+}
+
+// sqlState returns the SQLSTATE of an error returned by the database, or
+// an empty string if the error didn't come from the database.
+func sqlState(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
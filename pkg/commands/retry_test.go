@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		exp  bool
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, exp: true},
+		{name: "ambiguous result", err: &pgconn.PgError{Code: "40003"}, exp: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, exp: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, exp: true},
+		{name: "wrapped serialization failure", err: fmt.Errorf("writing: %w", &pgconn.PgError{Code: "40001"}), exp: true},
+		{name: "connection reset", err: syscall.ECONNRESET, exp: true},
+		{name: "connection refused", err: syscall.ECONNREFUSED, exp: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, exp: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, exp: false},
+		{name: "syntax error", err: &pgconn.PgError{Code: "42601"}, exp: false},
+		{name: "other error", err: errors.New("oh no"), exp: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, isRetryable(c.err))
+		})
+	}
+}
+
+func TestIsAmbiguous(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		exp  bool
+	}{
+		{name: "ambiguous result", err: &pgconn.PgError{Code: "40003"}, exp: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, exp: true},
+		{name: "connection reset", err: syscall.ECONNRESET, exp: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, exp: true},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, exp: false},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, exp: false},
+		{name: "connection refused", err: syscall.ECONNREFUSED, exp: false},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, exp: false},
+		{name: "other error", err: errors.New("oh no"), exp: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, isAmbiguous(c.err))
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Millisecond * 100, MaxBackoff: time.Second}
+
+	for retry := 1; retry <= 100; retry++ {
+		d := p.delay(retry)
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, time.Second)
+	}
+
+	for i := 0; i < 100; i++ {
+		assert.Less(t, p.delay(1), p.Backoff)
+	}
+
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.delay(1))
+}
+
+func TestRetryPolicyRetry(t *testing.T) {
+	retryable := &pgconn.PgError{Code: "40001"}
+	permanent := &pgconn.PgError{Code: "23505"}
+
+	cases := []struct {
+		name        string
+		attempts    int
+		errs        []error
+		expCalls    int
+		expRetries  int
+		expErr      error
+		expErrMatch string
+	}{
+		{
+			name:     "succeeds first time",
+			attempts: 3,
+			expCalls: 1,
+		},
+		{
+			name:       "succeeds after retries",
+			attempts:   3,
+			errs:       []error{retryable, retryable},
+			expCalls:   3,
+			expRetries: 2,
+		},
+		{
+			name:        "gives up",
+			attempts:    3,
+			errs:        []error{retryable, retryable, retryable},
+			expCalls:    3,
+			expRetries:  2,
+			expErr:      retryable,
+			expErrMatch: "giving up after 3 attempts",
+		},
+		{
+			name:        "non-retryable",
+			attempts:    3,
+			errs:        []error{permanent},
+			expCalls:    1,
+			expErr:      permanent,
+			expErrMatch: "non-retryable error",
+		},
+		{
+			name:        "no attempts configured",
+			errs:        []error{retryable},
+			expCalls:    1,
+			expErr:      retryable,
+			expErrMatch: "giving up after 1 attempts",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := RetryPolicy{Attempts: c.attempts, Backoff: time.Microsecond, MaxBackoff: time.Millisecond}
+
+			var calls, retries int
+			fn := func() error {
+				defer func() { calls++ }()
+				if calls < len(c.errs) {
+					return c.errs[calls]
+				}
+				return nil
+			}
+
+			err := p.retry(context.Background(), fn, func(int, error) { retries++ })
+
+			assert.Equal(t, c.expCalls, calls)
+			assert.Equal(t, c.expRetries, retries)
+			if c.expErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, c.expErr)
+			assert.ErrorContains(t, err, c.expErrMatch)
+		})
+	}
+}
+
+func TestRetryPolicyRetry_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := RetryPolicy{Attempts: 10, Backoff: time.Hour, MaxBackoff: time.Hour}
+	err := p.retry(ctx, func() error { return &pgconn.PgError{Code: "40001"} }, func(int, error) {})
+
+	assert.ErrorContains(t, err, "cancelled while retrying")
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/query"
+	"github.com/rs/zerolog"
+)
+
+// Serve exposes the files in a directory over HTTP, so that they can be
+// loaded by a database's IMPORT INTO statement, and prints an IMPORT INTO
+// statement for each table in the config that has a CSV file. Serve blocks
+// until the server fails.
+func Serve(addr, dir string, tables []model.Table, logger zerolog.Logger) error {
+	for _, stmt := range importStatements(baseURL(addr), dir, tables) {
+		fmt.Printf("%s;\n", stmt)
+	}
+
+	logger.Info().Str("addr", addr).Str("dir", dir).Msg("serving files")
+	if err := http.ListenAndServe(addr, fileServer(dir, logger)); err != nil {
+		return fmt.Errorf("serving files: %w", err)
+	}
+
+	return nil
+}
+
+// fileServer returns a handler that serves files from a directory. Range
+// requests are supported, allowing databases to fetch files in parts.
+func fileServer(dir string, logger zerolog.Logger) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.Info().
+			Str("path", r.URL.Path).
+			Str("range", r.Header.Get("Range")).
+			Msg("serving file")
+
+		files.ServeHTTP(w, r)
+	})
+}
+
+func importStatements(baseURL, dir string, tables []model.Table) []string {
+	var stmts []string
+	for _, t := range tables {
+		name := t.Name + ".csv"
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			continue
+		}
+
+		stmts = append(stmts, query.BuildImport(t, baseURL+"/"+name))
+	}
+
+	return stmts
+}
+
+// baseURL converts a listen address into a URL that a database running on
+// the same machine can reach.
+func baseURL(addr string) string {
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+
+	return "http://" + addr
+}
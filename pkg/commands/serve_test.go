@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileServer_Range(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv"), []byte("id\n1\n2\n"), 0644))
+
+	server := httptest.NewServer(fileServer(dir, test.NewNilLogger()))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/a.csv", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Range", "bytes=3-4")
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "1\n", string(body))
+}
+
+func TestImportStatements(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv"), []byte("id\n"), 0644))
+
+	tables := []model.Table{
+		{Name: "a", Columns: []model.Column{{Name: "id"}}},
+		{Name: "b", Columns: []model.Column{{Name: "id"}}},
+	}
+
+	act := importStatements(baseURL(":3000"), dir, tables)
+	assert.Equal(t, []string{
+		`IMPORT INTO a (id) CSV DATA ('http://localhost:3000/a.csv') WITH skip = '1', nullif = ''`,
+	}, act)
+}
@@ -0,0 +1,327 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/samber/lo"
+)
+
+// maxUniqueAttempts is the number of times a row's unique values are
+// regenerated before unique values are derived instead.
+const maxUniqueAttempts = 100
+
+// uniqueSet records the keys of values that have been generated, so that
+// they're never generated twice. It is safe for concurrent use, allowing
+// every worker generating a table to share one.
+type uniqueSet struct {
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+func newUniqueSet() *uniqueSet {
+	return &uniqueSet{
+		seen: map[string]struct{}{},
+	}
+}
+
+// add records a key, returning false if it had already been recorded.
+func (s *uniqueSet) add(key string) bool {
+	return len(s.addAll([]string{key})) == 0
+}
+
+// addAll records every key, unless any of them have already been
+// recorded, in which case nothing is recorded and the indexes of the
+// recorded keys are returned.
+func (s *uniqueSet) addAll(keys []string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recorded []int
+	for i, key := range keys {
+		if _, ok := s.seen[key]; ok {
+			recorded = append(recorded, i)
+		}
+	}
+
+	if len(recorded) > 0 {
+		return recorded
+	}
+
+	for _, key := range keys {
+		s.seen[key] = struct{}{}
+	}
+
+	return nil
+}
+
+// uniqueConstraint is a set of columns whose values, together, are never
+// repeated.
+type uniqueConstraint struct {
+	name    string
+	columns []int
+}
+
+// uniqueness enforces a table's unique constraints across every worker
+// generating it.
+type uniqueness struct {
+	constraints []uniqueConstraint
+
+	// continued constraints include columns whose values follow on from
+	// earlier rows, so can't be regenerated, and are only checked once
+	// they've been filled in.
+	continued []uniqueConstraint
+
+	seen    *uniqueSet
+	derived atomic.Int64
+}
+
+// newUniqueness returns the unique constraints of a table's unique
+// columns and column set, or nil if it has none.
+func newUniqueness(table model.Table) *uniqueness {
+	var constraints []uniqueConstraint
+	names := lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name })
+
+	for i, c := range table.Columns {
+		if c.Unique {
+			constraints = append(constraints, uniqueConstraint{name: c.Name, columns: []int{i}})
+		}
+	}
+
+	if len(table.Unique) > 0 {
+		constraints = append(constraints, uniqueConstraint{
+			name:    strings.Join(table.Unique, ", "),
+			columns: lo.Map(table.Unique, func(name string, _ int) int { return lo.IndexOf(names, name) }),
+		})
+	}
+
+	if len(constraints) == 0 {
+		return nil
+	}
+
+	u := uniqueness{seen: newUniqueSet()}
+	for _, c := range constraints {
+		if lo.SomeBy(c.columns, func(i int) bool { return continues(table, table.Columns[i]) }) {
+			u.continued = append(u.continued, c)
+		} else {
+			u.constraints = append(u.constraints, c)
+		}
+	}
+
+	return &u
+}
+
+// CheckContinuation returns an error if a run that continues from earlier
+// rows in a database, by appending or resuming, could repeat their unique
+// values or edges. Values are only unique among the rows generated by a
+// run, so continuing a table that has unique values or edges relies on the
+// conflict insert mode to skip (and replace) rows that repeat earlier ones. Tables that a
+// resumed run has already completed aren't generated, so aren't checked.
+func CheckContinuation(config model.Config, resume *Checkpoint, insertMode model.InsertMode) error {
+	if insertMode == model.InsertModeConflict {
+		return nil
+	}
+
+	for _, table := range config.Tables {
+		if resume != nil && resume.Tables[table.Name].Rows >= table.Rows {
+			continue
+		}
+
+		if newUniqueness(table) != nil {
+			return fmt.Errorf("table %q has unique values, which can only be kept unique across runs with --insert-mode conflict", table.Name)
+		}
+
+		if table.Edges != nil {
+			return fmt.Errorf("table %q has edges, which can only be kept unique across runs with --insert-mode conflict", table.Name)
+		}
+	}
+
+	return nil
+}
+
+// claim records a row's unique values, returning the constraints that it
+// would violate, in which case nothing is recorded. As with database
+// unique constraints, values containing NULL are never repeats.
+func (u *uniqueness) claim(row []any) []uniqueConstraint {
+	return u.claimAll(u.constraints, row)
+}
+
+// check records a row's unique values for its continued constraints, once
+// they've been filled in, failing if the row violates any of them.
+func (u *uniqueness) check(row []any) error {
+	if violated := u.claimAll(u.continued, row); len(violated) > 0 {
+		return fmt.Errorf("unable to generate unique values for %s, as its values follow on from earlier rows", violated[0].name)
+	}
+
+	return nil
+}
+
+func (u *uniqueness) claimAll(constraints []uniqueConstraint, row []any) []uniqueConstraint {
+	var keys []string
+	var checked []uniqueConstraint
+
+	for _, c := range constraints {
+		values := lo.Map(c.columns, func(col int, _ int) any { return row[col] })
+		if lo.Contains(values, nil) {
+			continue
+		}
+
+		key := lo.Map(values, func(v any, _ int) string { return model.FormatValue(v) })
+		keys = append(keys, fmt.Sprintf("%s\x00%s", c.name, strings.Join(key, "\x00")))
+		checked = append(checked, c)
+	}
+
+	return lo.Map(u.seen.addAll(keys), func(i int, _ int) uniqueConstraint { return checked[i] })
+}
+
+// makeUnique ensures that a row satisfies the table's unique constraints,
+// by regenerating the values of any constraints that it violates. If that
+// doesn't produce unique values, they're derived instead.
+func (g *DataGenerator) makeUnique(table model.Table, data *model.IterationData, u *uniqueness, row []any) error {
+	for attempt := 0; attempt < maxUniqueAttempts; attempt++ {
+		violated := u.claim(row)
+		if len(violated) == 0 {
+			return nil
+		}
+
+		for _, c := range violated {
+			for _, i := range c.columns {
+				if !regenerable(table, table.Columns[i]) {
+					continue
+				}
+
+				val, err := generateColumn(table.Columns[i], data)
+				if err != nil {
+					return fmt.Errorf("regenerating %q: %w", table.Columns[i].Name, err)
+				}
+				row[i] = val
+			}
+		}
+
+		if err := deriveValues(table, row); err != nil {
+			return fmt.Errorf("deriving values: %w", err)
+		}
+	}
+
+	return u.derive(table, data, row)
+}
+
+// derive changes a value in each violated constraint until the row is
+// unique. Strings are given a numeric suffix, and ref columns take each
+// of the values that they can reference in turn. Other values can't be
+// derived.
+func (u *uniqueness) derive(table model.Table, data *model.IterationData, row []any) error {
+	bases := map[int]string{}
+	cursors := map[int]*refCursor{}
+
+	for {
+		violated := u.claim(row)
+		if len(violated) == 0 {
+			return nil
+		}
+
+		for _, c := range violated {
+			i, ok := derivable(table, row, c)
+			if !ok {
+				return fmt.Errorf("unable to generate unique values for %s after %d attempts", c.name, maxUniqueAttempts)
+			}
+
+			if table.Columns[i].Mode == model.ColumnTypeRef {
+				cur, ok := cursors[i]
+				if !ok {
+					values := data.GetAllValues(table.Columns[i].RefKey())
+					cur = &refCursor{values: values, next: int(random.Int(0, int64(len(values)))), left: len(values)}
+					cursors[i] = cur
+				}
+
+				if cur.left == 0 {
+					return fmt.Errorf("unable to generate unique values for %s: every referenced value has been used", c.name)
+				}
+
+				row[i] = cur.values[cur.next%len(cur.values)]
+				cur.next++
+				cur.left--
+				continue
+			}
+
+			if _, ok := bases[i]; !ok {
+				bases[i] = row[i].(string)
+			}
+			row[i] = withSuffix(bases[i], u.derived.Add(1))
+		}
+
+		if err := deriveValues(table, row); err != nil {
+			return fmt.Errorf("deriving values: %w", err)
+		}
+	}
+}
+
+// continues returns true if a column's values follow on from earlier rows,
+// or are derived from a column whose values do.
+func continues(table model.Table, c model.Column) bool {
+	switch {
+	case table.Series != nil && table.Series.Computes(c.Name):
+		return true
+	case isStateful(c):
+		return true
+	case c.Mode == model.ColumnTypeUUIDv5:
+		source, ok := lo.Find(table.Columns, func(s model.Column) bool { return s.Name == c.UUIDv5 })
+		return ok && source.Mode != model.ColumnTypeUUIDv5 && continues(table, source)
+	default:
+		return false
+	}
+}
+
+// refCursor walks through the values that a ref column can reference,
+// starting from a random value.
+type refCursor struct {
+	values []any
+	next   int
+	left   int
+}
+
+// regenerable returns true if a column's values can be regenerated to make
+// a row unique. Incs are already unique, and edges are unique by design. A
+// series' per column can't change once the row has joined its series.
+func regenerable(table model.Table, c model.Column) bool {
+	if table.Series != nil && c.Name == table.Series.Per {
+		return false
+	}
+
+	switch c.Mode {
+	case model.ColumnTypeValue, model.ColumnTypeRange, model.ColumnTypeSet, model.ColumnTypeArray:
+		return true
+	case model.ColumnTypeRef:
+		return !c.Deferred && (table.Edges == nil || (c.Name != table.Edges.From && c.Name != table.Edges.To))
+	default:
+		return false
+	}
+}
+
+// derivable returns the index of the column in a constraint whose value can
+// be derived, favouring strings.
+func derivable(table model.Table, row []any, c uniqueConstraint) (int, bool) {
+	for _, i := range c.columns {
+		if _, ok := row[i].(string); ok && regenerable(table, table.Columns[i]) && table.Columns[i].Mode != model.ColumnTypeRef {
+			return i, true
+		}
+	}
+
+	return lo.Find(c.columns, func(i int) bool {
+		return table.Columns[i].Mode == model.ColumnTypeRef && regenerable(table, table.Columns[i])
+	})
+}
+
+// withSuffix adds a numeric suffix to a value, before the @ of values that
+// look like email addresses, so that they remain valid.
+func withSuffix(value string, n int64) string {
+	if local, domain, ok := strings.Cut(value, "@"); ok {
+		return fmt.Sprintf("%s_%d@%s", local, n, domain)
+	}
+
+	return fmt.Sprintf("%s_%d", value, n)
+}
@@ -0,0 +1,292 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/test"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniqueSet_AddAll(t *testing.T) {
+	sut := newUniqueSet()
+
+	assert.Empty(t, sut.addAll([]string{"a", "b"}))
+	assert.Equal(t, []int{1}, sut.addAll([]string{"c", "b"}))
+
+	// Nothing is recorded if any key has already been recorded.
+	assert.True(t, sut.add("c"))
+	assert.False(t, sut.add("a"))
+}
+
+func TestCheckContinuation(t *testing.T) {
+	config := model.Config{
+		Tables: []model.Table{
+			{Name: "plain", Rows: 10, Columns: []model.Column{{Name: "id"}}},
+			{Name: "person", Rows: 10, Columns: []model.Column{{Name: "email", Unique: true}}},
+			{Name: "follows", Rows: 10, Edges: &model.Edges{From: "follower_id", To: "followed_id"}},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		resume     *Checkpoint
+		insertMode model.InsertMode
+		expError   string
+	}{
+		{
+			name:       "unique values",
+			insertMode: model.InsertModeInsert,
+			expError:   `table "person" has unique values, which can only be kept unique across runs with --insert-mode conflict`,
+		},
+		{
+			name:       "edges",
+			resume:     &Checkpoint{Tables: map[string]TableCheckpoint{"person": {Rows: 10}}},
+			insertMode: model.InsertModeInsert,
+			expError:   `table "follows" has edges, which can only be kept unique across runs with --insert-mode conflict`,
+		},
+		{
+			name:       "conflict insert mode",
+			insertMode: model.InsertModeConflict,
+		},
+		{
+			name:       "resuming unfinished table",
+			resume:     &Checkpoint{Tables: map[string]TableCheckpoint{"person": {Rows: 5}}},
+			insertMode: model.InsertModeUpsert,
+			expError:   `table "person" has unique values, which can only be kept unique across runs with --insert-mode conflict`,
+		},
+		{
+			name:       "resuming finished tables",
+			resume:     &Checkpoint{Tables: map[string]TableCheckpoint{"person": {Rows: 10}, "follows": {Rows: 10}}},
+			insertMode: model.InsertModeUpsert,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckContinuation(config, c.resume, c.insertMode)
+			if c.expError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.expError)
+		})
+	}
+}
+
+func TestWithSuffix(t *testing.T) {
+	cases := []struct {
+		value string
+		exp   string
+	}{
+		{value: "red", exp: "red_3"},
+		{value: "jo@example.com", exp: "jo_3@example.com"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			assert.Equal(t, c.exp, withSuffix(c.value, 3))
+		})
+	}
+}
+
+func TestGenerate_Unique(t *testing.T) {
+	intProps, err := model.NewRawMessage(model.IntRange{Min: 1, Max: 3})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		table    model.Table
+		expError string
+	}{
+		{
+			name: "derived strings",
+			table: model.Table{
+				Columns: []model.Column{
+					{Name: "colour", Mode: model.ColumnTypeSet, Set: []string{"red", "green"}, Unique: true},
+				},
+			},
+		},
+		{
+			name: "column set",
+			table: model.Table{
+				Unique: []string{"colour", "size"},
+				Columns: []model.Column{
+					{Name: "colour", Mode: model.ColumnTypeSet, Set: []string{"red", "green", "blue"}},
+					{Name: "size", Mode: model.ColumnTypeSet, Set: []string{"s", "m", "l"}},
+				},
+			},
+		},
+		{
+			name: "every referenced value",
+			table: model.Table{
+				Columns: []model.Column{
+					{Name: "person_id", Mode: model.ColumnTypeRef, Ref: "person.id", Unique: true},
+				},
+			},
+		},
+		{
+			name: "underivable",
+			table: model.Table{
+				Columns: []model.Column{
+					{Name: "rank", Mode: model.ColumnTypeRange, Range: "int", Props: intProps, Unique: true},
+				},
+			},
+			expError: "unable to generate unique values for rank after 100 attempts",
+		},
+		{
+			name: "continued",
+			table: model.Table{
+				Columns: []model.Column{
+					{Name: "person_id", Mode: model.ColumnTypeRef, Ref: "person.id"},
+					{Name: "visit", Mode: model.ColumnTypeInc, NextID: lo.Must(model.NewSequence(1, model.IncProps{Per: "person_id"})), Unique: true},
+				},
+			},
+			expError: "unable to generate unique values for visit, as its values follow on from earlier rows",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			table := c.table
+			table.Name = "item"
+			table.Rows = 9
+
+			config := model.Config{
+				Tables: []model.Table{
+					{
+						Name:       "person",
+						Rows:       9,
+						Workers:    1,
+						RefColumns: []string{"id"},
+						Columns: []model.Column{
+							{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+						},
+					},
+					table,
+				},
+			}
+
+			writer := &recordingWriter{rows: map[string][][]any{}}
+
+			sut := NewDataGenerator(writer, test.NewNilLogger(), config, 3, 3, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+			err := sut.Generate(context.Background())
+			if c.expError != "" {
+				assert.ErrorContains(t, err, c.expError)
+				return
+			}
+			assert.NoError(t, err)
+
+			rows := writer.rows["item"]
+			assert.Len(t, rows, 9)
+
+			keys := lo.Map(rows, func(row []any, _ int) string { return fmt.Sprint(row...) })
+			assert.Len(t, lo.Uniq(keys), 9)
+		})
+	}
+}
+
+func TestGenerate_UniquePer(t *testing.T) {
+	lineNo, err := model.NewSequence(1, model.IncProps{Per: "purchase_id"})
+	assert.NoError(t, err)
+
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "purchase",
+				Rows:       3,
+				Workers:    1,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+				},
+			},
+			{
+				Name:   "purchase_line",
+				Rows:   9,
+				Unique: []string{"purchase_id", "product"},
+				Columns: []model.Column{
+					{Name: "purchase_id", Mode: model.ColumnTypeRef, Ref: "purchase.id"},
+					{Name: "product", Mode: model.ColumnTypeSet, Set: []string{"a", "b", "c"}},
+					{Name: "line_no", Mode: model.ColumnTypeInc, NextID: lineNo},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 3, 3, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["purchase_line"]
+	assert.Len(t, rows, 9)
+
+	// Every purchase has one line per product, numbered from 1 without
+	// gaps or repeats, even when rows are regenerated to be unique.
+	for _, lines := range lo.GroupBy(rows, func(row []any) any { return row[0] }) {
+		products := lo.Map(lines, func(row []any, _ int) any { return row[1] })
+		assert.ElementsMatch(t, []any{"a", "b", "c"}, products)
+
+		numbers := lo.Map(lines, func(row []any, _ int) any { return row[2] })
+		assert.ElementsMatch(t, []any{int64(1), int64(2), int64(3)}, numbers)
+	}
+}
+
+func TestGenerate_UniqueCumulative(t *testing.T) {
+	amount, err := model.NewRawMessage(model.IntRange{Min: 1, Max: 100})
+	assert.NoError(t, err)
+
+	version, err := model.NewSequence(1, model.IncProps{Per: "account_id"})
+	assert.NoError(t, err)
+
+	config := model.Config{
+		Tables: []model.Table{
+			{
+				Name:       "account",
+				Rows:       3,
+				Workers:    1,
+				RefColumns: []string{"id"},
+				Columns: []model.Column{
+					{Name: "id", Mode: model.ColumnTypeInc, NextID: model.Inc(1)},
+				},
+			},
+			{
+				Name:   "ledger",
+				Rows:   9,
+				Unique: []string{"account_id", "reference"},
+				Columns: []model.Column{
+					{Name: "account_id", Mode: model.ColumnTypeRef, Ref: "account.id"},
+					{Name: "reference", Mode: model.ColumnTypeSet, Set: []string{"a", "b", "c"}},
+					{Name: "version", Mode: model.ColumnTypeInc, NextID: version},
+					{Name: "amount", Mode: model.ColumnTypeRange, Range: "int", Props: amount},
+					{Name: "balance", Mode: model.ColumnTypeCumulative, Cumulative: "amount", Total: model.NewRunningTotal(model.CumulativeProps{Per: "account_id"})},
+				},
+			},
+		},
+	}
+
+	writer := &recordingWriter{rows: map[string][][]any{}}
+
+	sut := NewDataGenerator(writer, test.NewNilLogger(), config, 3, 3, 10, 1, RetryPolicy{}, CheckpointPolicy{})
+	assert.NoError(t, sut.Generate(context.Background()))
+
+	rows := writer.rows["ledger"]
+	assert.Len(t, rows, 9)
+
+	// Rows regenerated to be unique only count towards the total of the
+	// account they end up in.
+	for _, entries := range lo.GroupBy(rows, func(row []any) any { return row[0] }) {
+		slices.SortFunc(entries, func(a, b []any) int { return int(a[2].(int64) - b[2].(int64)) })
+
+		var balance int64
+		for _, entry := range entries {
+			balance += entry[3].(int64)
+			assert.Equal(t, balance, entry[4])
+		}
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"github.com/samber/lo"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,6 +20,18 @@ const (
 	ColumnTypeSet   ColumnType = "set"
 	ColumnTypeInc   ColumnType = "inc"
 	ColumnTypeArray ColumnType = "array"
+	ColumnTypeTree  ColumnType = "tree"
+
+	// ColumnTypeSeries columns are computed from a table's time series.
+	ColumnTypeSeries ColumnType = "series"
+
+	// ColumnTypeUUIDv5 columns are derived from another column in the
+	// same row.
+	ColumnTypeUUIDv5 ColumnType = "uuid_v5"
+
+	// ColumnTypeCumulative columns are running totals of another column
+	// in the same row.
+	ColumnTypeCumulative ColumnType = "cumulative"
 )
 
 type Config struct {
@@ -27,23 +41,107 @@ type Config struct {
 type Table struct {
 	Name    string   `yaml:"name"`
 	Rows    int      `yaml:"rows"`
+	Batch   int      `yaml:"batch,omitempty"`
+	Workers int      `yaml:"workers,omitempty"`
+	Writers int      `yaml:"writers,omitempty"`
+	Tree    *Tree    `yaml:"tree,omitempty"`
+	Edges   *Edges   `yaml:"edges,omitempty"`
+	Series  *Series  `yaml:"series,omitempty"`
 	Columns []Column `yaml:"columns"`
 
+	// Unique is a set of columns whose values, together, are never
+	// repeated.
+	Unique []string `yaml:"unique,omitempty"`
+
 	RefColumns []string `yaml:"-"`
+
+	// Existing holds values of the table's referenced columns that were
+	// already in the database before generation, keyed by column name.
+	Existing map[string][]any `yaml:"-"`
 }
 
 type Column struct {
-	Name  string      `yaml:"name"`
-	Mode  ColumnType  `yaml:"-"`
-	Value string      `yaml:"value,omitempty"`
-	Array string      `yaml:"array,omitempty"`
-	Range string      `yaml:"range,omitempty"`
-	Props *RawMessage `yaml:"props,omitempty"`
-	Ref   string      `yaml:"ref,omitempty"`
-	Set   []string    `yaml:"set,omitempty"`
-	Inc   int64       `yaml:"inc,omitempty"`
+	Name     string      `yaml:"name"`
+	Mode     ColumnType  `yaml:"-"`
+	Value    string      `yaml:"value,omitempty"`
+	Array    string      `yaml:"array,omitempty"`
+	Range    string      `yaml:"range,omitempty"`
+	Props    *RawMessage `yaml:"props,omitempty"`
+	Ref      string      `yaml:"ref,omitempty"`
+	RefQuery string      `yaml:"ref_query,omitempty"`
+	RefFile  string      `yaml:"ref_file,omitempty"`
+	Set      []string    `yaml:"set,omitempty"`
+	SetFile  string      `yaml:"set_file,omitempty"`
+	Inc      int64       `yaml:"inc,omitempty"`
+	UUIDv5   string      `yaml:"uuid_v5,omitempty"`
+
+	// Cumulative columns are a running total of the named column.
+	Cumulative string `yaml:"cumulative,omitempty"`
+
+	// Unique columns never repeat a value.
+	Unique bool `yaml:"unique,omitempty"`
+
+	// Distinct columns sample their values from a pool of this many
+	// distinct values.
+	Distinct int `yaml:"distinct,omitempty"`
+
+	// Deferred ref columns are written as NULL and filled in once every
+	// table has been generated, breaking cycles between tables.
+	Deferred bool `yaml:"deferred,omitempty"`
+
+	NextID *Sequence `yaml:"-"`
+
+	// Total holds the running totals of a cumulative column.
+	Total *RunningTotal `yaml:"-"`
+
+	// RefValues holds the values returned by a ref_query or read from a
+	// ref_file.
+	RefValues []any `yaml:"-"`
+
+	// Pool holds the values of a distinct column.
+	Pool []any `yaml:"-"`
+}
 
-	NextID Sequence `yaml:"-"`
+// RefKey returns the key of the values that a ref column samples from.
+func (c Column) RefKey() string {
+	switch {
+	case c.RefQuery != "":
+		return "query:" + c.RefQuery
+	case c.RefFile != "":
+		return "file:" + c.RefFile
+	default:
+		return c.Ref
+	}
+}
+
+// UUIDv5Props configures a uuid_v5 column.
+type UUIDv5Props struct {
+	// Namespace is the UUID that values are derived within (defaults to
+	// the URL namespace).
+	Namespace string `yaml:"namespace"`
+}
+
+// UUIDv5Namespace returns the namespace of a uuid_v5 column.
+func (c Column) UUIDv5Namespace() (uuid.UUID, error) {
+	if c.Props == nil {
+		return uuid.NameSpaceURL, nil
+	}
+
+	var x UUIDv5Props
+	if err := c.Props.Unmarshal(&x); err != nil {
+		return uuid.UUID{}, fmt.Errorf("decoding uuid_v5 props: %w", err)
+	}
+
+	if x.Namespace == "" {
+		return uuid.NameSpaceURL, nil
+	}
+
+	namespace, err := uuid.Parse(x.Namespace)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("parsing uuid_v5 namespace: %w", err)
+	}
+
+	return namespace, nil
 }
 
 type IntRange struct {
@@ -81,11 +179,24 @@ func ParseConfig(yamlData string, logger zerolog.Logger) (Config, error) {
 	}
 
 	// Set mode and initialize any mode dependencies.
-	for _, table := range config.Tables {
+	for t := range config.Tables {
+		table := &config.Tables[t]
 		logger.Info().Str("table", table.Name).Msg("parsing column types")
 
+		if err = validateConcurrency(*table); err != nil {
+			return Config{}, fmt.Errorf("parsing table %q: %w", table.Name, err)
+		}
+
+		if err = prepareTree(table); err != nil {
+			return Config{}, fmt.Errorf("parsing tree for table %q: %w", table.Name, err)
+		}
+
+		if err = prepareSeries(table); err != nil {
+			return Config{}, fmt.Errorf("parsing series for table %q: %w", table.Name, err)
+		}
+
 		for i := range table.Columns {
-			if err = parseColumn(&table, i); err != nil {
+			if err = parseColumn(table, i); err != nil {
 				return Config{}, fmt.Errorf("parsing column %q: %w", table.Columns[i].Name, err)
 			}
 			logger.Info().
@@ -93,11 +204,34 @@ func ParseConfig(yamlData string, logger zerolog.Logger) (Config, error) {
 				Str("mode", string(table.Columns[i].Mode)).
 				Msgf("parsed column")
 		}
+
+		if err = prepareEdges(table); err != nil {
+			return Config{}, fmt.Errorf("parsing edges for table %q: %w", table.Name, err)
+		}
+
+		if err = validateUnique(*table); err != nil {
+			return Config{}, fmt.Errorf("parsing unique columns for table %q: %w", table.Name, err)
+		}
+
+		if err = validateDerived(*table); err != nil {
+			return Config{}, fmt.Errorf("parsing derived columns for table %q: %w", table.Name, err)
+		}
 	}
 
 	// Mark tables that are dependencies on others.
 	markDependencies(&config)
 
+	// Break any cycles between tables, so that they can be generated.
+	for _, column := range BreakCycles(config.Tables) {
+		logger.Info().Str("column", column).Msg("deferring column to break cycle")
+	}
+
+	for t := range config.Tables {
+		if err = prepareDeferred(&config.Tables[t]); err != nil {
+			return Config{}, fmt.Errorf("parsing deferred columns for table %q: %w", config.Tables[t].Name, err)
+		}
+	}
+
 	return config, nil
 }
 
@@ -127,45 +261,293 @@ func markDependencies(c *Config) {
 
 func parseColumn(table *Table, i int) error {
 	switch {
+	case table.Tree != nil && table.Tree.Computes(table.Columns[i].Name):
+		table.Columns[i].Mode = ColumnTypeTree
+	case table.Series != nil && table.Series.Computes(table.Columns[i].Name):
+		table.Columns[i].Mode = ColumnTypeSeries
+	case table.Columns[i].UUIDv5 != "":
+		table.Columns[i].Mode = ColumnTypeUUIDv5
+	case table.Columns[i].Cumulative != "":
+		table.Columns[i].Mode = ColumnTypeCumulative
+
+		var props CumulativeProps
+		if table.Columns[i].Props != nil {
+			if err := table.Columns[i].Props.Unmarshal(&props); err != nil {
+				return fmt.Errorf("decoding cumulative props: %w", err)
+			}
+		}
+		table.Columns[i].Total = NewRunningTotal(props)
 	case table.Columns[i].Value != "":
 		table.Columns[i].Mode = ColumnTypeValue
 	case table.Columns[i].Range != "":
 		table.Columns[i].Mode = ColumnTypeRange
-	case table.Columns[i].Ref != "":
+	case table.Columns[i].Ref != "", table.Columns[i].RefQuery != "", table.Columns[i].RefFile != "":
 		table.Columns[i].Mode = ColumnTypeRef
-	case table.Columns[i].Set != nil:
+	case table.Columns[i].Set != nil, table.Columns[i].SetFile != "":
 		table.Columns[i].Mode = ColumnTypeSet
 	case table.Columns[i].Inc != 0:
 		table.Columns[i].Mode = ColumnTypeInc
-		table.Columns[i].NextID = Inc(table.Columns[i].Inc)
+
+		var props IncProps
+		if table.Columns[i].Props != nil {
+			if err := table.Columns[i].Props.Unmarshal(&props); err != nil {
+				return fmt.Errorf("decoding inc props: %w", err)
+			}
+		}
+
+		seq, err := NewSequence(table.Columns[i].Inc, props)
+		if err != nil {
+			return err
+		}
+		table.Columns[i].NextID = seq
 	case table.Columns[i].Array != "":
 		table.Columns[i].Mode = ColumnTypeArray
 	default:
 		return fmt.Errorf("missing value, range, ref, or set for column")
 	}
+
+	switch c := table.Columns[i]; {
+	case c.Distinct < 0:
+		return fmt.Errorf("invalid distinct value count: %d", c.Distinct)
+	case c.Distinct > 0 && (c.Mode == ColumnTypeInc || c.Mode == ColumnTypeTree || c.Mode == ColumnTypeSeries || c.Mode == ColumnTypeUUIDv5 || c.Mode == ColumnTypeCumulative):
+		return fmt.Errorf("distinct isn't supported for %s columns", c.Mode)
+	}
+
 	return nil
 }
 
-// Sort tables by their inter-dependence.
-func SortTables(tables []Table) ([]Table, error) {
-	dependencies := make(map[string][]string)
-	inDegree := make(map[string]int)
+// validateConcurrency checks that a table's batch size, worker count, and
+// writer count are positive, if they're set.
+func validateConcurrency(table Table) error {
+	switch {
+	case table.Batch < 0:
+		return fmt.Errorf("invalid batch size: %d", table.Batch)
+	case table.Workers < 0:
+		return fmt.Errorf("invalid worker count: %d", table.Workers)
+	case table.Writers < 0:
+		return fmt.Errorf("invalid writer count: %d", table.Writers)
+	default:
+		return nil
+	}
+}
+
+// validateUnique checks that a table's unique columns exist.
+func validateUnique(table Table) error {
+	names := lo.Map(table.Columns, func(c Column, _ int) string { return c.Name })
+
+	for _, name := range table.Unique {
+		if !lo.Contains(names, name) {
+			return fmt.Errorf("missing unique column: %q", name)
+		}
+	}
+
+	return nil
+}
+
+// validateDerived checks that a table's derived columns are derived from
+// columns that exist, and aren't derived themselves.
+func validateDerived(table Table) error {
+	for _, c := range table.Columns {
+		if c.Mode == ColumnTypeInc && c.NextID != nil && c.NextID.Props().Per != "" {
+			per := c.NextID.Props().Per
+			if per == c.Name || !lo.ContainsBy(table.Columns, func(s Column) bool { return s.Name == per }) {
+				return fmt.Errorf("column %q has a sequence per missing column %q", c.Name, per)
+			}
+		}
+
+		if c.Mode == ColumnTypeCumulative {
+			if err := validateCumulative(table, c); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if c.Mode != ColumnTypeUUIDv5 {
+			continue
+		}
+
+		source, ok := lo.Find(table.Columns, func(s Column) bool { return s.Name == c.UUIDv5 })
+		if !ok {
+			return fmt.Errorf("column %q is derived from missing column %q", c.Name, c.UUIDv5)
+		}
 
-	// Initialize inDegree for all tables.
+		if source.Mode == ColumnTypeUUIDv5 {
+			return fmt.Errorf("column %q is derived from derived column %q", c.Name, c.UUIDv5)
+		}
+
+		if _, err := c.UUIDv5Namespace(); err != nil {
+			return fmt.Errorf("column %q: %w", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateCumulative checks that a cumulative column totals, and is per,
+// columns that exist. Derived columns can't be totalled, as they may not
+// have been derived yet.
+func validateCumulative(table Table, c Column) error {
+	source, ok := lo.Find(table.Columns, func(s Column) bool { return s.Name == c.Cumulative })
+	if !ok || source.Name == c.Name {
+		return fmt.Errorf("column %q totals missing column %q", c.Name, c.Cumulative)
+	}
+
+	perInc := source.Mode == ColumnTypeInc && source.NextID != nil && source.NextID.Props().Per != ""
+	if source.Mode == ColumnTypeCumulative || source.Mode == ColumnTypeUUIDv5 || perInc {
+		return fmt.Errorf("column %q totals derived column %q", c.Name, c.Cumulative)
+	}
+
+	if per := c.Total.Props().Per; per != "" {
+		if per == c.Name || !lo.ContainsBy(table.Columns, func(s Column) bool { return s.Name == per }) {
+			return fmt.Errorf("column %q has a total per missing column %q", c.Name, per)
+		}
+	}
+
+	return nil
+}
+
+// UniqueKey returns the first of a table's columns whose values never
+// repeat, which can identify its rows: either a unique column, or an inc
+// column that neither wraps around nor restarts per another column.
+func (t Table) UniqueKey() (string, bool) {
+	c, ok := lo.Find(t.Columns, func(c Column) bool {
+		if c.Unique {
+			return true
+		}
+
+		return c.Mode == ColumnTypeInc && c.NextID != nil && c.NextID.Props().Max == 0 && c.NextID.Props().Per == ""
+	})
+
+	return c.Name, ok
+}
+
+// prepareDeferred checks that a table with deferred columns has a unique
+// key to identify the rows to fill them in for, and records the key's
+// values, along with those of its referenced columns.
+func prepareDeferred(table *Table) error {
+	c, ok := lo.Find(table.Columns, func(c Column) bool { return c.Deferred })
+	if !ok {
+		return nil
+	}
+
+	key, ok := table.UniqueKey()
+	if !ok {
+		return fmt.Errorf("column %q is deferred, but there's no inc or unique column to identify rows by", c.Name)
+	}
+
+	if !lo.Contains(table.RefColumns, key) {
+		table.RefColumns = append(table.RefColumns, key)
+	}
+
+	return nil
+}
+
+// TableDependencies returns the distinct names of the tables that each
+// table references. References to tables that aren't in the given set of
+// tables, references from a table to itself, and deferred references are
+// ignored, as none of them require the referenced table to be generated
+// first.
+func TableDependencies(tables []Table) map[string][]string {
+	names := make(map[string]struct{}, len(tables))
 	for _, table := range tables {
-		inDegree[table.Name] = 0
+		names[table.Name] = struct{}{}
 	}
 
-	// Populate dependencies and in-degree map.
+	dependencies := make(map[string][]string, len(tables))
 	for _, table := range tables {
+		dependencies[table.Name] = []string{}
+
 		for _, col := range table.Columns {
-			if col.Ref == "" {
+			if col.Ref == "" || col.Deferred {
 				continue
 			}
 
 			refTable := strings.Split(col.Ref, ".")[0]
-			dependencies[refTable] = append(dependencies[refTable], table.Name)
-			inDegree[table.Name]++
+			if _, ok := names[refTable]; !ok || refTable == table.Name {
+				continue
+			}
+
+			if !lo.Contains(dependencies[table.Name], refTable) {
+				dependencies[table.Name] = append(dependencies[table.Name], refTable)
+			}
+		}
+	}
+
+	return dependencies
+}
+
+// BreakCycles defers ref columns until no cycles remain between tables,
+// returning the deferred columns as table.column. Only references that
+// form part of a cycle are deferred, favouring those in tables that appear
+// earlier in the config.
+func BreakCycles(tables []Table) []string {
+	var deferred []string
+
+	for {
+		table, column, ok := findCycleColumn(tables)
+		if !ok {
+			return deferred
+		}
+
+		column.Deferred = true
+		deferred = append(deferred, fmt.Sprintf("%s.%s", table, column.Name))
+	}
+}
+
+// findCycleColumn returns the first ref column whose reference forms part
+// of a cycle, which is the case if the referenced table depends on the
+// referencing table, directly or indirectly.
+func findCycleColumn(tables []Table) (string, *Column, bool) {
+	dependencies := TableDependencies(tables)
+
+	for i := range tables {
+		for j := range tables[i].Columns {
+			c := &tables[i].Columns[j]
+			if c.Ref == "" || c.Deferred {
+				continue
+			}
+
+			refTable := strings.Split(c.Ref, ".")[0]
+			if refTable == tables[i].Name {
+				continue
+			}
+
+			if dependsOn(dependencies, refTable, tables[i].Name, map[string]bool{}) {
+				return tables[i].Name, c, true
+			}
+		}
+	}
+
+	return "", nil, false
+}
+
+// dependsOn returns true if table from depends on table to.
+func dependsOn(dependencies map[string][]string, from, to string, visited map[string]bool) bool {
+	if visited[from] {
+		return false
+	}
+	visited[from] = true
+
+	for _, parent := range dependencies[from] {
+		if parent == to || dependsOn(dependencies, parent, to, visited) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sort tables by their inter-dependence.
+func SortTables(tables []Table) ([]Table, error) {
+	dependents := make(map[string][]string)
+	inDegree := make(map[string]int)
+
+	// Populate dependents and in-degree map.
+	for table, refTables := range TableDependencies(tables) {
+		inDegree[table] = len(refTables)
+
+		for _, refTable := range refTables {
+			dependents[refTable] = append(dependents[refTable], table)
 		}
 	}
 
@@ -174,9 +556,9 @@ func SortTables(tables []Table) ([]Table, error) {
 	queue := []string{}
 
 	// Start with tables that have no incoming edges (in-degree 0).
-	for table, degree := range inDegree {
-		if degree == 0 {
-			queue = append(queue, table)
+	for _, table := range tables {
+		if inDegree[table.Name] == 0 {
+			queue = append(queue, table.Name)
 		}
 	}
 
@@ -194,7 +576,7 @@ func SortTables(tables []Table) ([]Table, error) {
 		}
 
 		// Decrease in-degree of dependent tables.
-		for _, dependentTable := range dependencies[currentTable] {
+		for _, dependentTable := range dependents[currentTable] {
 			inDegree[dependentTable]--
 			if inDegree[dependentTable] == 0 {
 				queue = append(queue, dependentTable)
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"testing"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -111,6 +112,22 @@ func TestParseColumn(t *testing.T) {
 			},
 			expectedMode: ColumnTypeRef,
 		},
+		{
+			name: "ref query type",
+			column: Column{
+				Name:     "col",
+				RefQuery: "SELECT id FROM member",
+			},
+			expectedMode: ColumnTypeRef,
+		},
+		{
+			name: "ref file type",
+			column: Column{
+				Name:    "col",
+				RefFile: "ids.csv#id",
+			},
+			expectedMode: ColumnTypeRef,
+		},
 		{
 			name: "set type",
 			column: Column{
@@ -119,6 +136,14 @@ func TestParseColumn(t *testing.T) {
 			},
 			expectedMode: ColumnTypeSet,
 		},
+		{
+			name: "set file type",
+			column: Column{
+				Name:    "col",
+				SetFile: "countries.txt",
+			},
+			expectedMode: ColumnTypeSet,
+		},
 		{
 			name: "missing mode",
 			column: Column{
@@ -126,6 +151,41 @@ func TestParseColumn(t *testing.T) {
 			},
 			expError: errors.New("missing value, range, ref, or set for column"),
 		},
+		{
+			name: "uuid_v5 type",
+			column: Column{
+				Name:   "col",
+				UUIDv5: "email",
+			},
+			expectedMode: ColumnTypeUUIDv5,
+		},
+		{
+			name: "distinct value",
+			column: Column{
+				Name:     "col",
+				Value:    "${city}",
+				Distinct: 10,
+			},
+			expectedMode: ColumnTypeValue,
+		},
+		{
+			name: "negative distinct",
+			column: Column{
+				Name:     "col",
+				Value:    "${city}",
+				Distinct: -1,
+			},
+			expError: errors.New("invalid distinct value count: -1"),
+		},
+		{
+			name: "distinct inc",
+			column: Column{
+				Name:     "col",
+				Inc:      1,
+				Distinct: 10,
+			},
+			expError: errors.New("distinct isn't supported for inc columns"),
+		},
 	}
 
 	for _, tt := range tests {
@@ -144,3 +204,340 @@ func TestParseColumn(t *testing.T) {
 		})
 	}
 }
+
+func TestTableDependencies(t *testing.T) {
+	tables := []Table{
+		{Name: "a"},
+		{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}, {Name: "other_a_id", Ref: "a.id"}}},
+		{Name: "c", Columns: []Column{{Name: "a_id", Ref: "a.id"}, {Name: "b_id", Ref: "b.id"}, {Name: "x_id", Ref: "x.id"}}},
+		{Name: "d", Columns: []Column{{Name: "a_id", RefQuery: "SELECT id FROM a"}}},
+		{Name: "e", Columns: []Column{{Name: "parent_id", Ref: "e.id"}, {Name: "d_id", Ref: "d.id", Deferred: true}}},
+	}
+
+	exp := map[string][]string{
+		"a": {},
+		"b": {"a"},
+		"c": {"a", "b"},
+		"d": {},
+		"e": {},
+	}
+
+	assert.Equal(t, exp, TableDependencies(tables))
+}
+
+func TestColumnRefKey(t *testing.T) {
+	assert.Equal(t, "a.id", Column{Ref: "a.id"}.RefKey())
+	assert.Equal(t, "query:SELECT id FROM a", Column{RefQuery: "SELECT id FROM a"}.RefKey())
+	assert.Equal(t, "file:ids.csv#id", Column{RefFile: "ids.csv#id"}.RefKey())
+}
+
+func TestBreakCycles(t *testing.T) {
+	cases := []struct {
+		name        string
+		tables      []Table
+		expDeferred []string
+	}{
+		{
+			name: "no cycles",
+			tables: []Table{
+				{Name: "a"},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+		},
+		{
+			name: "self reference",
+			tables: []Table{
+				{Name: "a", Columns: []Column{{Name: "parent_id", Ref: "a.id"}}},
+			},
+		},
+		{
+			name: "mutual references",
+			tables: []Table{
+				{Name: "a", Columns: []Column{{Name: "b_id", Ref: "b.id"}}},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+			expDeferred: []string{"a.b_id"},
+		},
+		{
+			name: "longer cycle with dependent table",
+			tables: []Table{
+				{Name: "d", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+				{Name: "a", Columns: []Column{{Name: "b_id", Ref: "b.id"}}},
+				{Name: "b", Columns: []Column{{Name: "c_id", Ref: "c.id"}}},
+				{Name: "c", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+			expDeferred: []string{"a.b_id"},
+		},
+		{
+			name: "already deferred",
+			tables: []Table{
+				{Name: "a", Columns: []Column{{Name: "b_id", Ref: "b.id", Deferred: true}}},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			act := BreakCycles(c.tables)
+			assert.Equal(t, c.expDeferred, act)
+
+			_, err := SortTables(c.tables)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSortTables(t *testing.T) {
+	cases := []struct {
+		name     string
+		tables   []Table
+		expOrder []string
+		expError bool
+	}{
+		{
+			name: "already sorted",
+			tables: []Table{
+				{Name: "a"},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+			expOrder: []string{"a", "b"},
+		},
+		{
+			name: "reverse order",
+			tables: []Table{
+				{Name: "c", Columns: []Column{{Name: "b_id", Ref: "b.id"}}},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+				{Name: "a"},
+			},
+			expOrder: []string{"a", "b", "c"},
+		},
+		{
+			name: "cyclic",
+			tables: []Table{
+				{Name: "a", Columns: []Column{{Name: "b_id", Ref: "b.id"}}},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+			expError: true,
+		},
+		{
+			name: "cycle broken by deferred column",
+			tables: []Table{
+				{Name: "a", Columns: []Column{{Name: "b_id", Ref: "b.id", Deferred: true}}},
+				{Name: "b", Columns: []Column{{Name: "a_id", Ref: "a.id"}}},
+			},
+			expOrder: []string{"a", "b"},
+		},
+		{
+			name: "self reference",
+			tables: []Table{
+				{Name: "employee", Columns: []Column{{Name: "manager_id", Ref: "employee.id"}}},
+			},
+			expOrder: []string{"employee"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			act, err := SortTables(c.tables)
+			if c.expError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expOrder, lo.Map(act, func(t Table, _ int) string { return t.Name }))
+		})
+	}
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	cases := []struct {
+		name     string
+		table    Table
+		expError string
+	}{
+		{name: "defaults", table: Table{}},
+		{name: "overridden", table: Table{Batch: 100, Workers: 2, Writers: 4}},
+		{name: "negative batch", table: Table{Batch: -1}, expError: "invalid batch size: -1"},
+		{name: "negative workers", table: Table{Workers: -1}, expError: "invalid worker count: -1"},
+		{name: "negative writers", table: Table{Writers: -1}, expError: "invalid writer count: -1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateConcurrency(c.table)
+			if c.expError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.expError)
+		})
+	}
+}
+
+func TestValidateUnique(t *testing.T) {
+	table := Table{
+		Unique:  []string{"a", "b"},
+		Columns: []Column{{Name: "a"}, {Name: "b"}},
+	}
+	assert.NoError(t, validateUnique(table))
+
+	table.Unique = []string{"a", "c"}
+	assert.EqualError(t, validateUnique(table), `missing unique column: "c"`)
+}
+
+func TestValidateDerived(t *testing.T) {
+	namespace, err := NewRawMessage(UUIDv5Props{Namespace: "not a uuid"})
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name     string
+		columns  []Column
+		expError string
+	}{
+		{
+			name: "valid",
+			columns: []Column{
+				{Name: "email", Mode: ColumnTypeValue},
+				{Name: "id", Mode: ColumnTypeUUIDv5, UUIDv5: "email"},
+			},
+		},
+		{
+			name: "missing source",
+			columns: []Column{
+				{Name: "id", Mode: ColumnTypeUUIDv5, UUIDv5: "email"},
+			},
+			expError: `column "id" is derived from missing column "email"`,
+		},
+		{
+			name: "missing per column",
+			columns: []Column{
+				{Name: "line", Mode: ColumnTypeInc, NextID: lo.Must(NewSequence(1, IncProps{Per: "order_id"}))},
+			},
+			expError: `column "line" has a sequence per missing column "order_id"`,
+		},
+		{
+			name: "derived source",
+			columns: []Column{
+				{Name: "a", Mode: ColumnTypeUUIDv5, UUIDv5: "b"},
+				{Name: "b", Mode: ColumnTypeUUIDv5, UUIDv5: "a"},
+			},
+			expError: `column "a" is derived from derived column "b"`,
+		},
+		{
+			name: "invalid namespace",
+			columns: []Column{
+				{Name: "email", Mode: ColumnTypeValue},
+				{Name: "id", Mode: ColumnTypeUUIDv5, UUIDv5: "email", Props: namespace},
+			},
+			expError: `column "id": parsing uuid_v5 namespace: invalid UUID length: 10`,
+		},
+		{
+			name: "valid cumulative",
+			columns: []Column{
+				{Name: "account_id", Mode: ColumnTypeRef},
+				{Name: "amount", Mode: ColumnTypeRange},
+				{Name: "balance", Mode: ColumnTypeCumulative, Cumulative: "amount", Total: NewRunningTotal(CumulativeProps{Per: "account_id"})},
+			},
+		},
+		{
+			name: "missing cumulative source",
+			columns: []Column{
+				{Name: "balance", Mode: ColumnTypeCumulative, Cumulative: "amount", Total: NewRunningTotal(CumulativeProps{})},
+			},
+			expError: `column "balance" totals missing column "amount"`,
+		},
+		{
+			name: "derived cumulative source",
+			columns: []Column{
+				{Name: "amount", Mode: ColumnTypeRange},
+				{Name: "balance", Mode: ColumnTypeCumulative, Cumulative: "amount", Total: NewRunningTotal(CumulativeProps{})},
+				{Name: "total", Mode: ColumnTypeCumulative, Cumulative: "balance", Total: NewRunningTotal(CumulativeProps{})},
+			},
+			expError: `column "total" totals derived column "balance"`,
+		},
+		{
+			name: "missing cumulative per column",
+			columns: []Column{
+				{Name: "amount", Mode: ColumnTypeRange},
+				{Name: "balance", Mode: ColumnTypeCumulative, Cumulative: "amount", Total: NewRunningTotal(CumulativeProps{Per: "account_id"})},
+			},
+			expError: `column "balance" has a total per missing column "account_id"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateDerived(Table{Columns: c.columns})
+			if c.expError != "" {
+				assert.EqualError(t, err, c.expError)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestPrepareDeferred(t *testing.T) {
+	cases := []struct {
+		name          string
+		table         Table
+		expRefColumns []string
+		expError      string
+	}{
+		{
+			name: "no deferred columns",
+			table: Table{
+				Columns: []Column{{Name: "id", Mode: ColumnTypeValue}},
+			},
+		},
+		{
+			name: "inc key",
+			table: Table{
+				RefColumns: []string{"code"},
+				Columns: []Column{
+					{Name: "code", Mode: ColumnTypeValue},
+					{Name: "id", Mode: ColumnTypeInc, NextID: Inc(1)},
+					{Name: "head_id", Mode: ColumnTypeRef, Deferred: true},
+				},
+			},
+			expRefColumns: []string{"code", "id"},
+		},
+		{
+			name: "unique key",
+			table: Table{
+				RefColumns: []string{"id"},
+				Columns: []Column{
+					{Name: "id", Mode: ColumnTypeValue, Unique: true},
+					{Name: "head_id", Mode: ColumnTypeRef, Deferred: true},
+				},
+			},
+			expRefColumns: []string{"id"},
+		},
+		{
+			name: "no key",
+			table: Table{
+				RefColumns: []string{"id"},
+				Columns: []Column{
+					{Name: "id", Mode: ColumnTypeValue},
+					{Name: "line", Mode: ColumnTypeInc, NextID: lo.Must(NewSequence(1, IncProps{Max: 10}))},
+					{Name: "head_id", Mode: ColumnTypeRef, Deferred: true},
+				},
+			},
+			expError: `column "head_id" is deferred, but there's no inc or unique column to identify rows by`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := prepareDeferred(&c.table)
+			if c.expError != "" {
+				assert.EqualError(t, err, c.expError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expRefColumns, c.table.RefColumns)
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// CumulativeProps configures a cumulative column.
+type CumulativeProps struct {
+	// Start is the total before the first row (defaults to 0).
+	Start float64 `yaml:"start"`
+
+	// Per is a column in the same row, for each of whose values there's a
+	// separate total.
+	Per string `yaml:"per"`
+}
+
+// RunningTotal is a thread-safe running total of a column's values. Totals
+// of integers remain integers, unless the start or a value is a float.
+// Values are added as rows are generated, and committed once they're
+// written, so that the totals of written rows can be saved and restored.
+type RunningTotal struct {
+	props CumulativeProps
+
+	mu        sync.Mutex
+	totals    map[string]*Total
+	committed map[string]*Total
+}
+
+// Total is the running total of a single key, which is held both as an
+// integer and a float until a float is added to it.
+type Total struct {
+	N     int64   `json:"n"`
+	F     float64 `json:"f"`
+	Float bool    `json:"float,omitempty"`
+}
+
+// NewRunningTotal returns a running total configured by props.
+func NewRunningTotal(props CumulativeProps) *RunningTotal {
+	return &RunningTotal{
+		props:     props,
+		totals:    map[string]*Total{},
+		committed: map[string]*Total{},
+	}
+}
+
+// Props returns the running total's configuration.
+func (r *RunningTotal) Props() CumulativeProps {
+	return r.props
+}
+
+// Add adds a value to the total for a given key, with each key having a
+// separate total, and returns the new total. NULL values leave the total
+// unchanged.
+func (r *RunningTotal) Add(key string, v any) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.add(r.totals, key, v)
+}
+
+// Commit adds a written row's value to the committed total for a given
+// key.
+func (r *RunningTotal) Commit(key string, v any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.add(r.committed, key, v)
+	return err
+}
+
+// add adds a value to a key's total in totals. The caller must hold mu.
+func (r *RunningTotal) add(totals map[string]*Total, key string, v any) (any, error) {
+	t, ok := totals[key]
+	if !ok {
+		t = &Total{N: int64(r.props.Start), F: r.props.Start, Float: r.props.Start != math.Trunc(r.props.Start)}
+		totals[key] = t
+	}
+
+	if v != nil {
+		switch x := reflect.ValueOf(v); x.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			t.N += x.Int()
+			t.F += float64(x.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			t.N += int64(x.Uint())
+			t.F += float64(x.Uint())
+		case reflect.Float32, reflect.Float64:
+			t.F += x.Float()
+			t.Float = true
+		default:
+			return nil, fmt.Errorf("unable to total %T values", v)
+		}
+	}
+
+	if t.Float {
+		return t.F, nil
+	}
+	return t.N, nil
+}
+
+// Totals returns the committed total of each key that has been committed
+// to.
+func (r *RunningTotal) Totals() map[string]Total {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totals := make(map[string]Total, len(r.committed))
+	for key, t := range r.committed {
+		totals[key] = *t
+	}
+
+	return totals
+}
+
+// Reset sets both the total and the committed total of a given key, so
+// that the next value added to the key is added to t.
+func (r *RunningTotal) Reset(key string, t Total) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	committed := t
+	r.totals[key] = &t
+	r.committed[key] = &committed
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunningTotal(t *testing.T) {
+	cases := []struct {
+		name   string
+		props  CumulativeProps
+		values []any
+		exp    []any
+	}{
+		{
+			name:   "integers",
+			values: []any{int64(10), int64(-3), int32(5)},
+			exp:    []any{int64(10), int64(7), int64(12)},
+		},
+		{
+			name:   "start",
+			props:  CumulativeProps{Start: 100},
+			values: []any{int64(10), int64(-3)},
+			exp:    []any{int64(110), int64(107)},
+		},
+		{
+			name:   "float start",
+			props:  CumulativeProps{Start: 0.5},
+			values: []any{int64(1), int64(2)},
+			exp:    []any{1.5, 3.5},
+		},
+		{
+			name:   "floats",
+			values: []any{int64(1), 0.5, int64(2)},
+			exp:    []any{int64(1), 1.5, 3.5},
+		},
+		{
+			name:   "nulls",
+			values: []any{int64(1), nil, int64(2)},
+			exp:    []any{int64(1), int64(1), int64(3)},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sut := NewRunningTotal(c.props)
+
+			var act []any
+			for _, v := range c.values {
+				total, err := sut.Add("", v)
+				assert.NoError(t, err)
+				act = append(act, total)
+			}
+
+			assert.Equal(t, c.exp, act)
+		})
+	}
+}
+
+func TestRunningTotal_Keys(t *testing.T) {
+	sut := NewRunningTotal(CumulativeProps{})
+
+	for _, key := range []string{"a", "b", "a"} {
+		_, err := sut.Add(key, int64(1))
+		assert.NoError(t, err)
+	}
+
+	a, err := sut.Add("a", int64(0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), a)
+
+	b, err := sut.Add("b", int64(0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), b)
+}
+
+func TestRunningTotal_Invalid(t *testing.T) {
+	_, err := NewRunningTotal(CumulativeProps{}).Add("", "ten")
+	assert.EqualError(t, err, "unable to total string values")
+}
+
+func TestRunningTotal_Totals(t *testing.T) {
+	sut := NewRunningTotal(CumulativeProps{Start: 10})
+
+	_, err := sut.Add("a", int64(5))
+	assert.NoError(t, err)
+	_, err = sut.Add("b", 2.5)
+	assert.NoError(t, err)
+
+	// Only committed values count towards the saved totals.
+	assert.Empty(t, sut.Totals())
+
+	assert.NoError(t, sut.Commit("a", int64(5)))
+	assert.NoError(t, sut.Commit("b", 2.5))
+
+	assert.Equal(t, map[string]Total{
+		"a": {N: 15, F: 15},
+		"b": {N: 10, F: 12.5, Float: true},
+	}, sut.Totals())
+
+	// Restored totals continue from where they were.
+	restored := NewRunningTotal(CumulativeProps{Start: 10})
+	for key, total := range sut.Totals() {
+		restored.Reset(key, total)
+	}
+
+	a, err := restored.Add("a", int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(16), a)
+
+	b, err := restored.Add("b", int64(1))
+	assert.NoError(t, err)
+	assert.Equal(t, 13.5, b)
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/samber/lo"
+)
+
+// Edge distributions.
+const (
+	EdgeDistributionUniform  = "uniform"
+	EdgeDistributionPowerLaw = "power_law"
+)
+
+// Edges configures a table whose rows are edges between two referenced
+// columns, such as a follows(follower_id, followee_id) junction table.
+// Every pair of values appears at most once.
+type Edges struct {
+	// From and To are the ref columns that hold each edge's endpoints.
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+
+	// SelfEdges allows edges whose endpoints are the same value.
+	SelfEdges bool `yaml:"self_edges,omitempty"`
+
+	// Distribution is how often each value is picked as an endpoint,
+	// either "uniform" (the default), or "power_law", in which a few values
+	// have many edges, and most values have few.
+	Distribution string `yaml:"distribution,omitempty"`
+
+	// Exponent controls how skewed a power law distribution is, with
+	// higher values concentrating more edges on the most popular values
+	// (defaults to 1).
+	Exponent float64 `yaml:"exponent,omitempty"`
+}
+
+// prepareEdges applies a table's edge defaults, and checks that its
+// endpoints are ref columns.
+func prepareEdges(table *Table) error {
+	e := table.Edges
+	if e == nil {
+		return nil
+	}
+
+	if table.Tree != nil {
+		return fmt.Errorf("a table can't have both edges and a tree")
+	}
+
+	if e.From == "" || e.To == "" {
+		return fmt.Errorf("missing edge from or to column")
+	}
+
+	if e.From == e.To {
+		return fmt.Errorf("edge from and to columns must be different: %q", e.From)
+	}
+
+	e.Distribution = lo.Ternary(e.Distribution != "", e.Distribution, EdgeDistributionUniform)
+	if e.Distribution != EdgeDistributionUniform && e.Distribution != EdgeDistributionPowerLaw {
+		return fmt.Errorf("invalid edge distribution: %q", e.Distribution)
+	}
+
+	e.Exponent = lo.Ternary(e.Exponent != 0, e.Exponent, 1)
+	if e.Exponent < 0 {
+		return fmt.Errorf("invalid edge exponent: %v", e.Exponent)
+	}
+
+	for _, name := range []string{e.From, e.To} {
+		c, ok := lo.Find(table.Columns, func(c Column) bool { return c.Name == name })
+		if !ok {
+			return fmt.Errorf("missing edge column: %q", name)
+		}
+
+		if c.Mode != ColumnTypeRef {
+			return fmt.Errorf("edge column %q must be a ref column", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareEdges(t *testing.T) {
+	table := Table{
+		Name:  "follows",
+		Edges: &Edges{From: "follower_id", To: "followee_id"},
+		Columns: []Column{
+			{Name: "follower_id", Mode: ColumnTypeRef, Ref: "person.id"},
+			{Name: "followee_id", Mode: ColumnTypeRef, Ref: "person.id"},
+		},
+	}
+
+	assert.NoError(t, prepareEdges(&table))
+	assert.Equal(t, EdgeDistributionUniform, table.Edges.Distribution)
+	assert.Equal(t, 1.0, table.Edges.Exponent)
+}
+
+func TestPrepareEdges_Invalid(t *testing.T) {
+	columns := []Column{
+		{Name: "a", Mode: ColumnTypeRef, Ref: "person.id"},
+		{Name: "b", Mode: ColumnTypeRef, Ref: "person.id"},
+		{Name: "c", Mode: ColumnTypeInc},
+	}
+
+	cases := []struct {
+		name     string
+		table    Table
+		expError string
+	}{
+		{
+			name:     "missing column name",
+			table:    Table{Edges: &Edges{From: "a"}, Columns: columns},
+			expError: "missing edge from or to column",
+		},
+		{
+			name:     "same column",
+			table:    Table{Edges: &Edges{From: "a", To: "a"}, Columns: columns},
+			expError: `edge from and to columns must be different: "a"`,
+		},
+		{
+			name:     "invalid distribution",
+			table:    Table{Edges: &Edges{From: "a", To: "b", Distribution: "normal"}, Columns: columns},
+			expError: `invalid edge distribution: "normal"`,
+		},
+		{
+			name:     "missing column",
+			table:    Table{Edges: &Edges{From: "a", To: "d"}, Columns: columns},
+			expError: `missing edge column: "d"`,
+		},
+		{
+			name:     "not a ref column",
+			table:    Table{Edges: &Edges{From: "a", To: "c"}, Columns: columns},
+			expError: `edge column "c" must be a ref column`,
+		},
+		{
+			name:     "tree",
+			table:    Table{Tree: &Tree{}, Edges: &Edges{From: "a", To: "b"}, Columns: columns},
+			expError: "a table can't have both edges and a tree",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.EqualError(t, prepareEdges(&c.table), c.expError)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// LoadColumnFiles reads the values of every set_file and ref_file column
+// in the config. Relative paths are resolved against dir, which is
+// typically the directory containing the config file.
+func LoadColumnFiles(config Config, dir string) error {
+	files := map[string][]any{}
+
+	read := func(path string) ([]any, error) {
+		if values, ok := files[path]; ok {
+			return values, nil
+		}
+
+		values, err := ReadValues(resolvePath(dir, path))
+		if err != nil {
+			return nil, err
+		}
+
+		files[path] = values
+		return values, nil
+	}
+
+	for _, table := range config.Tables {
+		for i, c := range table.Columns {
+			switch {
+			case c.SetFile != "":
+				values, err := read(c.SetFile)
+				if err != nil {
+					return fmt.Errorf("reading set_file for %s.%s: %w", table.Name, c.Name, err)
+				}
+
+				table.Columns[i].Set = lo.Map(values, func(v any, _ int) string {
+					return FormatValue(v)
+				})
+
+			case c.RefFile != "":
+				values, err := read(c.RefFile)
+				if err != nil {
+					return fmt.Errorf("reading ref_file for %s.%s: %w", table.Name, c.Name, err)
+				}
+
+				table.Columns[i].RefValues = values
+			}
+		}
+	}
+
+	return nil
+}
+
+// ReadValues reads a list of values from a file, whose format depends on
+// its extension:
+//
+//   - .json files contain an array of values.
+//   - .csv files contain a header row, followed by values. The column to
+//     read is given after a # (e.g. customers.csv#id), or is otherwise the
+//     first column.
+//   - Any other file contains one value per line, ignoring blank lines.
+func ReadValues(path string) ([]any, error) {
+	path, column, _ := strings.Cut(path, "#")
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return readJSONValues(b)
+	case ".csv":
+		return readCSVValues(b, column)
+	default:
+		return readLineValues(b)
+	}
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func readJSONValues(b []byte) ([]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var values []any
+	if err := dec.Decode(&values); err != nil {
+		return nil, fmt.Errorf("parsing json array: %w", err)
+	}
+
+	for i, v := range values {
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+
+		// Whole numbers are kept exact, so that large integer keys survive.
+		if i64, err := n.Int64(); err == nil {
+			values[i] = i64
+		} else if f, err := n.Float64(); err == nil {
+			values[i] = f
+		} else {
+			values[i] = n.String()
+		}
+	}
+
+	return values, nil
+}
+
+func readCSVValues(b []byte, column string) ([]any, error) {
+	r := csv.NewReader(bytes.NewReader(b))
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+
+	index := 0
+	if column != "" {
+		if index = lo.IndexOf(header, column); index == -1 {
+			return nil, fmt.Errorf("missing csv column: %q", column)
+		}
+	}
+
+	var values []any
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading csv record: %w", err)
+		}
+
+		values = append(values, record[index])
+	}
+
+	return values, nil
+}
+
+func readLineValues(b []byte) ([]any, error) {
+	var values []any
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			values = append(values, line)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading lines: %w", err)
+	}
+
+	return values, nil
+}
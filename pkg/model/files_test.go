@@ -0,0 +1,107 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadValues(t *testing.T) {
+	cases := []struct {
+		name     string
+		file     string
+		content  string
+		fragment string
+		exp      []any
+		expError string
+	}{
+		{
+			name:    "text",
+			file:    "countries.txt",
+			content: "United Kingdom\n\n  France \nGermany\n",
+			exp:     []any{"United Kingdom", "France", "Germany"},
+		},
+		{
+			name:    "csv first column",
+			file:    "customers.csv",
+			content: "id,name\n1,a\n2,\"b, c\"\n",
+			exp:     []any{"1", "2"},
+		},
+		{
+			name:     "csv named column",
+			file:     "customers.csv",
+			content:  "id,name\n1,a\n2,\"b, c\"\n",
+			fragment: "#name",
+			exp:      []any{"a", "b, c"},
+		},
+		{
+			name:     "csv missing column",
+			file:     "customers.csv",
+			content:  "id,name\n1,a\n",
+			fragment: "#email",
+			expError: `missing csv column: "email"`,
+		},
+		{
+			name:    "json",
+			file:    "ids.json",
+			content: `["a", 9007199254740993, 1.5, true]`,
+			exp:     []any{"a", int64(9007199254740993), 1.5, true},
+		},
+		{
+			name:     "json not an array",
+			file:     "ids.json",
+			content:  `{"a": 1}`,
+			expError: "parsing json array",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), c.file)
+			assert.NoError(t, os.WriteFile(path, []byte(c.content), 0644))
+
+			act, err := ReadValues(path + c.fragment)
+			if c.expError != "" {
+				assert.ErrorContains(t, err, c.expError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.exp, act)
+		})
+	}
+}
+
+func TestLoadColumnFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "countries.txt"), []byte("uk\nfr\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "ids.json"), []byte(`[1, 2]`), 0644))
+
+	config := Config{
+		Tables: []Table{
+			{
+				Name: "customer",
+				Columns: []Column{
+					{Name: "country", SetFile: "countries.txt"},
+					{Name: "legacy_id", RefFile: filepath.Join(dir, "ids.json")},
+				},
+			},
+		},
+	}
+
+	assert.NoError(t, LoadColumnFiles(config, dir))
+	assert.Equal(t, []string{"uk", "fr"}, config.Tables[0].Columns[0].Set)
+	assert.Equal(t, []any{int64(1), int64(2)}, config.Tables[0].Columns[1].RefValues)
+}
+
+func TestLoadColumnFiles_Missing(t *testing.T) {
+	config := Config{
+		Tables: []Table{
+			{Name: "customer", Columns: []Column{{Name: "country", SetFile: "missing.txt"}}},
+		},
+	}
+
+	assert.ErrorContains(t, LoadColumnFiles(config, t.TempDir()), "reading set_file for customer.country")
+}
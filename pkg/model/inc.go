@@ -1,17 +1,205 @@
 package model
 
-import "sync/atomic"
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+)
 
-type Sequence func() int64
+// IncProps configures an inc column.
+type IncProps struct {
+	// Step is the difference between consecutive numbers (defaults to 1).
+	Step int64 `yaml:"step"`
+
+	// Max is the largest number in the sequence, after which it wraps
+	// around to its start (unlimited if 0).
+	Max int64 `yaml:"max"`
+
+	// Format is a fmt format that numbers are written with, such as
+	// "ORD-%06d" (numbers are written as integers if empty).
+	Format string `yaml:"format"`
+
+	// Per is a column in the same row, for each of whose values there's a
+	// separate sequence.
+	Per string `yaml:"per"`
+}
+
+// Sequence is a thread-safe sequence of increasing numbers, whose position
+// can be saved and restored.
+type Sequence struct {
+	start int64
+	props IncProps
+	next  atomic.Int64
+
+	perMu sync.Mutex
+	per   map[string]*Sequence
+}
 
 // Inc returns a thread-safe sequence generator, starting from
 // a given number.
-func Inc(start int64) Sequence {
-	// Reduce start by one so that the first increment results in
-	// the expected value.
-	start--
+func Inc(start int64) *Sequence {
+	s, _ := NewSequence(start, IncProps{})
+	return s
+}
+
+// NewSequence returns a thread-safe sequence generator, starting from a
+// given number and configured by props.
+func NewSequence(start int64, props IncProps) (*Sequence, error) {
+	if props.Step == 0 {
+		props.Step = 1
+	}
+
+	if props.Step < 0 {
+		return nil, fmt.Errorf("invalid inc step: %d", props.Step)
+	}
+
+	if props.Max != 0 && props.Max < start {
+		return nil, fmt.Errorf("inc max %d is less than its start %d", props.Max, start)
+	}
+
+	if props.Format != "" && strings.Contains(fmt.Sprintf(props.Format, start), "%!") {
+		return nil, fmt.Errorf("invalid inc format: %q", props.Format)
+	}
+
+	s := &Sequence{start: start, props: props}
+	s.next.Store(start)
+	return s, nil
+}
+
+// Props returns the sequence's configuration.
+func (s *Sequence) Props() IncProps {
+	return s.props
+}
+
+// Next returns the next number in the sequence. Sequences with a max wrap
+// around to their start after the last step that doesn't pass it, so that
+// every number is a whole number of steps from the start.
+func (s *Sequence) Next() int64 {
+	n := s.next.Add(s.props.Step) - s.props.Step
+	if s.props.Max == 0 {
+		return n
+	}
+
+	steps := (s.props.Max-s.start)/s.props.Step + 1
+	return s.start + ((n-s.start)/s.props.Step%steps)*s.props.Step
+}
+
+// NextFor returns the next number in the sequence for a given key, with
+// each key having a separate sequence.
+func (s *Sequence) NextFor(key string) int64 {
+	return s.sequenceFor(key).Next()
+}
+
+// sequenceFor returns the sequence of a given key, creating it if the key
+// hasn't been numbered yet.
+func (s *Sequence) sequenceFor(key string) *Sequence {
+	s.perMu.Lock()
+	defer s.perMu.Unlock()
+
+	seq, ok := s.per[key]
+	if !ok {
+		if s.per == nil {
+			s.per = map[string]*Sequence{}
+		}
+
+		seq = &Sequence{start: s.start, props: s.props}
+		seq.next.Store(s.start)
+		s.per[key] = seq
+	}
 
-	return func() int64 {
-		return atomic.AddInt64(&start, 1)
+	return seq
+}
+
+// Positions returns the position of the sequence of each key that has
+// been numbered.
+func (s *Sequence) Positions() map[string]int64 {
+	s.perMu.Lock()
+	defer s.perMu.Unlock()
+
+	positions := make(map[string]int64, len(s.per))
+	for key, seq := range s.per {
+		positions[key] = seq.Position()
+	}
+
+	return positions
+}
+
+// ResetFor moves the sequence of a given key, so that the next call to
+// NextFor with the key returns next.
+func (s *Sequence) ResetFor(key string, next int64) {
+	s.sequenceFor(key).Reset(next)
+}
+
+// SkipPastFor moves the sequence of a given key past n, as SkipPast does.
+func (s *Sequence) SkipPastFor(key string, n int64) {
+	s.sequenceFor(key).SkipPast(n)
+}
+
+// Value returns a number from the sequence, formatted if the sequence has
+// a format.
+func (s *Sequence) Value(n int64) any {
+	if s.props.Format == "" {
+		return n
+	}
+
+	return fmt.Sprintf(s.props.Format, n)
+}
+
+// Affixes returns the text that a formatted sequence writes before and
+// after each number, which is only possible for formats with a single
+// decimal verb, such as "ORD-%06d". Unformatted sequences have neither.
+func (s *Sequence) Affixes() (prefix, suffix string, err error) {
+	format := s.props.Format
+	if format == "" {
+		return "", "", nil
 	}
+
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+
+		if i+1 < len(format) && format[i+1] == '%' {
+			i++
+			continue
+		}
+
+		end := strings.IndexFunc(format[i+1:], unicode.IsLetter)
+		if end == -1 || format[i+1+end] != 'd' {
+			return "", "", fmt.Errorf("inc format %q isn't a decimal format", format)
+		}
+
+		prefix, suffix = format[:i], format[i+2+end:]
+		if strings.Contains(strings.ReplaceAll(suffix, "%%", ""), "%") {
+			return "", "", fmt.Errorf("inc format %q has more than one verb", format)
+		}
+
+		return strings.ReplaceAll(prefix, "%%", "%"), strings.ReplaceAll(suffix, "%%", "%"), nil
+	}
+
+	return "", "", fmt.Errorf("inc format %q has no verb", format)
+}
+
+// Position returns the number that the next call to Next will return,
+// before any wrapping around.
+func (s *Sequence) Position() int64 {
+	return s.next.Load()
+}
+
+// Reset moves the sequence, so that the next call to Next returns next.
+func (s *Sequence) Reset(next int64) {
+	s.next.Store(next)
+}
+
+// SkipPast moves the sequence, so that the next call to Next returns the
+// first number in the sequence that's larger than n, if the sequence
+// hasn't already passed it.
+func (s *Sequence) SkipPast(n int64) {
+	if n < s.Position() {
+		return
+	}
+
+	s.Reset(s.start + ((n-s.start)/s.props.Step+1)*s.props.Step)
 }
@@ -0,0 +1,192 @@
+package model
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	sut := Inc(10)
+
+	assert.Equal(t, int64(10), sut.Next())
+	assert.Equal(t, int64(11), sut.Next())
+	assert.Equal(t, int64(12), sut.Position())
+
+	sut.Reset(100)
+	assert.Equal(t, int64(100), sut.Next())
+}
+
+func TestSequence_Concurrent(t *testing.T) {
+	sut := Inc(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sut.Next()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1001), sut.Position())
+}
+
+func TestSequence_Props(t *testing.T) {
+	cases := []struct {
+		name  string
+		start int64
+		props IncProps
+		exp   []any
+	}{
+		{
+			name:  "step",
+			start: 10,
+			props: IncProps{Step: 5},
+			exp:   []any{int64(10), int64(15), int64(20), int64(25)},
+		},
+		{
+			name:  "wrap",
+			start: 1,
+			props: IncProps{Max: 3},
+			exp:   []any{int64(1), int64(2), int64(3), int64(1), int64(2)},
+		},
+		{
+			name:  "wrap with step",
+			start: 0,
+			props: IncProps{Step: 2, Max: 5},
+			exp:   []any{int64(0), int64(2), int64(4), int64(0), int64(2)},
+		},
+		{
+			name:  "wrap with step that doesn't divide range",
+			start: 1,
+			props: IncProps{Step: 3, Max: 10},
+			exp:   []any{int64(1), int64(4), int64(7), int64(10), int64(1), int64(4), int64(7), int64(10), int64(1)},
+		},
+		{
+			name:  "wrap with step past max",
+			start: 1,
+			props: IncProps{Step: 3, Max: 8},
+			exp:   []any{int64(1), int64(4), int64(7), int64(1), int64(4), int64(7), int64(1)},
+		},
+		{
+			name:  "format",
+			start: 123,
+			props: IncProps{Format: "ORD-%06d"},
+			exp:   []any{"ORD-000123", "ORD-000124"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sut, err := NewSequence(c.start, c.props)
+			assert.NoError(t, err)
+
+			var act []any
+			for range c.exp {
+				act = append(act, sut.Value(sut.Next()))
+			}
+			assert.Equal(t, c.exp, act)
+		})
+	}
+}
+
+func TestSequence_Invalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		start    int64
+		props    IncProps
+		expError string
+	}{
+		{name: "negative step", start: 1, props: IncProps{Step: -1}, expError: "invalid inc step: -1"},
+		{name: "max before start", start: 10, props: IncProps{Max: 5}, expError: "inc max 5 is less than its start 10"},
+		{name: "format without verb", start: 1, props: IncProps{Format: "ORD"}, expError: `invalid inc format: "ORD"`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewSequence(c.start, c.props)
+			assert.EqualError(t, err, c.expError)
+		})
+	}
+}
+
+func TestSequence_NextFor(t *testing.T) {
+	sut := Inc(1)
+
+	assert.Equal(t, int64(1), sut.NextFor("a"))
+	assert.Equal(t, int64(2), sut.NextFor("a"))
+	assert.Equal(t, int64(1), sut.NextFor("b"))
+	assert.Equal(t, int64(3), sut.NextFor("a"))
+
+	// Per sequences don't move the sequence itself.
+	assert.Equal(t, int64(1), sut.Next())
+}
+
+func TestSequence_SkipPast(t *testing.T) {
+	sut, err := NewSequence(10, IncProps{Step: 10})
+	assert.NoError(t, err)
+
+	sut.SkipPast(35)
+	assert.Equal(t, int64(40), sut.Next())
+
+	// Sequences that have already passed a number aren't moved back.
+	sut.SkipPast(20)
+	assert.Equal(t, int64(50), sut.Next())
+}
+
+func TestSequence_Positions(t *testing.T) {
+	sut := Inc(1)
+	sut.NextFor("a")
+	sut.NextFor("a")
+	sut.NextFor("b")
+
+	assert.Equal(t, map[string]int64{"a": 3, "b": 2}, sut.Positions())
+
+	restored := Inc(1)
+	for key, next := range sut.Positions() {
+		restored.ResetFor(key, next)
+	}
+	restored.SkipPastFor("c", 4)
+
+	assert.Equal(t, int64(3), restored.NextFor("a"))
+	assert.Equal(t, int64(2), restored.NextFor("b"))
+	assert.Equal(t, int64(5), restored.NextFor("c"))
+}
+
+func TestSequence_Affixes(t *testing.T) {
+	cases := []struct {
+		name      string
+		format    string
+		expPrefix string
+		expSuffix string
+		expError  string
+	}{
+		{name: "unformatted"},
+		{name: "prefix", format: "ORD-%06d", expPrefix: "ORD-"},
+		{name: "prefix and suffix", format: "v%d.0", expPrefix: "v", expSuffix: ".0"},
+		{name: "escaped percent", format: "%d%%", expSuffix: "%"},
+		{name: "not decimal", format: "%x", expError: `inc format "%x" isn't a decimal format`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sut, err := NewSequence(1, IncProps{Format: c.format})
+			assert.NoError(t, err)
+
+			prefix, suffix, err := sut.Affixes()
+			if c.expError != "" {
+				assert.EqualError(t, err, c.expError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.expPrefix, prefix)
+			assert.Equal(t, c.expSuffix, suffix)
+		})
+	}
+}
@@ -2,20 +2,66 @@ package model
 
 import (
 	"fmt"
+	"sync"
 
-	"github.com/samber/lo"
+	"github.com/codingconcepts/dgs/pkg/random"
 )
 
+// DefaultPoolSize is the number of generated values of each referenced
+// column that are kept for other tables to reference.
+const DefaultPoolSize = 10000
+
+// IterationData holds a sample of the generated values of columns that
+// other tables reference, along with any values that existed before
+// generation started. It is safe for concurrent use.
 type IterationData struct {
+	mu   sync.RWMutex
 	data map[string][]any
+	base map[string][]any
+
+	// poolSize is the maximum number of values held in data for each
+	// column, and added counts the values that have been added to it, so
+	// that data remains a uniform sample of every value added.
+	poolSize int
+	added    map[string]int
+
+	// all holds every generated value of retained columns.
+	all map[string][]any
 }
 
 func NewIterationData() *IterationData {
 	return &IterationData{
-		data: map[string][]any{},
+		data:     map[string][]any{},
+		base:     map[string][]any{},
+		poolSize: DefaultPoolSize,
+		added:    map[string]int{},
+		all:      map[string][]any{},
 	}
 }
 
+// Retain keeps every value generated for a referenced column, rather than
+// only a sample of them, for columns whose every row matters.
+func (d *IterationData) Retain(ref string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, ok := d.all[ref]; !ok {
+		d.all[ref] = []any{}
+	}
+}
+
+// SeedData adds values that can be referenced for the rest of generation,
+// in addition to the sampled generated values.
+func (d *IterationData) SeedData(ref string, values []any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.base[ref] = append(d.base[ref], values...)
+}
+
+// AddData adds the values of a column from a batch of rows. Once a column
+// has more values than the pool holds, each new value replaces a random
+// value in the pool with a decreasing probability (reservoir sampling), so
+// that every value written so far is equally likely to be referenced.
 func (d *IterationData) AddData(rows [][]any, table, column string, index int) {
 	var values []any
 	for _, row := range rows {
@@ -23,13 +69,118 @@ func (d *IterationData) AddData(rows [][]any, table, column string, index int) {
 	}
 
 	refKey := fmt.Sprintf("%s.%s", table, column)
-	d.data[refKey] = values
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sample(refKey, values)
+
+	if all, ok := d.all[refKey]; ok {
+		d.all[refKey] = append(all, values...)
+	}
+}
+
+// sample adds values to a column's pool. The caller must hold mu.
+func (d *IterationData) sample(ref string, values []any) {
+	pool := d.data[ref]
+	for _, v := range values {
+		d.added[ref]++
+
+		if len(pool) < d.poolSize {
+			pool = append(pool, v)
+			continue
+		}
+
+		if i := random.Int(0, int64(d.added[ref])); i < int64(d.poolSize) {
+			pool[i] = v
+		}
+	}
+	d.data[ref] = pool
 }
 
+// GetValue returns a random value from either the sampled generated values
+// or the seeded values, with every value equally likely.
 func (d *IterationData) GetValue(ref string) any {
-	return lo.Sample(d.data[ref])
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	base, latest := d.base[ref], d.data[ref]
+	if len(base) == 0 {
+		return random.Sample(latest)
+	}
+
+	i := random.Int(0, int64(len(base)+len(latest)))
+	if i < int64(len(base)) {
+		return base[i]
+	}
+	return latest[i-int64(len(base))]
 }
 
+// GetValues returns the sampled generated values of a referenced column.
 func (d *IterationData) GetValues(ref string) []any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
 	return d.data[ref]
 }
+
+// GetAllValues returns a copy of the seeded values of a referenced column,
+// followed by its generated values, which are every generated value if
+// the column is retained, or a sample of them if not.
+func (d *IterationData) GetAllValues(ref string) []any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	generated := d.generated(ref)
+	values := make([]any, 0, len(d.base[ref])+len(generated))
+	values = append(values, d.base[ref]...)
+	return append(values, generated...)
+}
+
+// GetGeneratedValues returns a copy of a referenced column's generated
+// values, without its seeded values.
+func (d *IterationData) GetGeneratedValues(ref string) []any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return append([]any(nil), d.generated(ref)...)
+}
+
+func (d *IterationData) generated(ref string) []any {
+	if all, ok := d.all[ref]; ok {
+		return all
+	}
+	return d.data[ref]
+}
+
+// Snapshot returns a copy of the sampled generated values, or every
+// generated value of retained columns, of each referenced column that
+// keep returns true for.
+func (d *IterationData) Snapshot(keep func(ref string) bool) map[string][]any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	snapshot := make(map[string][]any, len(d.data))
+	for k := range d.data {
+		if keep(k) {
+			snapshot[k] = append([]any(nil), d.generated(k)...)
+		}
+	}
+
+	return snapshot
+}
+
+// Load replaces the generated values of referenced columns with those from
+// a snapshot.
+func (d *IterationData) Load(snapshot map[string][]any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, v := range snapshot {
+		delete(d.data, k)
+		d.added[k] = 0
+		d.sample(k, v)
+
+		if _, ok := d.all[k]; ok {
+			d.all[k] = append([]any(nil), v...)
+		}
+	}
+}
@@ -24,6 +24,28 @@ func TestIterationData_Add(t *testing.T) {
 	assert.Equal(t, exp, sut.data)
 }
 
+func TestIterationData_Pool(t *testing.T) {
+	sut := NewIterationData()
+	sut.poolSize = 100
+
+	for b := 0; b < 100; b++ {
+		sut.AddData(lo.Map(lo.Range(100), func(i, _ int) []any { return []any{b*100 + i} }), "table", "id", 0)
+	}
+
+	// A final partial batch doesn't shrink the pool.
+	sut.AddData([][]any{{10000}}, "table", "id", 0)
+
+	values := sut.GetValues("table.id")
+	assert.Len(t, values, 100)
+	assert.Equal(t, 10001, sut.added["table.id"])
+
+	// Values from every part of the run are sampled, not just the most
+	// recent batches.
+	early := lo.CountBy(values, func(v any) bool { return v.(int) < 5000 })
+	assert.Greater(t, early, 20)
+	assert.Less(t, early, 80)
+}
+
 func TestIterationData_Sample(t *testing.T) {
 	sut := &IterationData{
 		data: map[string][]any{
@@ -35,3 +57,65 @@ func TestIterationData_Sample(t *testing.T) {
 
 	assert.True(t, lo.Contains(sut.data["table.id"], sample))
 }
+
+func TestIterationData_SnapshotLoad(t *testing.T) {
+	source := NewIterationData()
+	source.AddData([][]any{{1}, {2}}, "table", "id", 0)
+
+	snapshot := source.Snapshot(func(string) bool { return true })
+
+	// Changes to the source after a snapshot aren't reflected in it.
+	source.AddData([][]any{{3}}, "table", "id", 0)
+
+	sut := NewIterationData()
+	sut.Load(snapshot)
+
+	assert.Equal(t, []any{1, 2}, sut.GetValues("table.id"))
+
+	// Only the columns that are kept are included.
+	assert.Empty(t, source.Snapshot(func(string) bool { return false }))
+}
+
+func TestIterationData_SeedData(t *testing.T) {
+	sut := NewIterationData()
+	sut.SeedData("table.id", []any{"a", "b"})
+
+	// Seeded values are available before anything is generated.
+	assert.Contains(t, []any{"a", "b"}, sut.GetValue("table.id"))
+
+	// Generated values are sampled alongside seeded values.
+	sut.AddData([][]any{{"c"}}, "table", "id", 0)
+
+	seen := map[any]bool{}
+	for i := 0; i < 1000; i++ {
+		seen[sut.GetValue("table.id")] = true
+	}
+	assert.Equal(t, map[any]bool{"a": true, "b": true, "c": true}, seen)
+}
+
+func TestIterationData_GetAllValues(t *testing.T) {
+	sut := NewIterationData()
+	sut.SeedData("table.id", []any{"a", "b"})
+	sut.AddData([][]any{{"c"}}, "table", "id", 0)
+
+	assert.Equal(t, []any{"a", "b", "c"}, sut.GetAllValues("table.id"))
+}
+
+func TestIterationData_Retain(t *testing.T) {
+	sut := NewIterationData()
+	sut.Retain("table.id")
+	sut.SeedData("table.id", []any{"a"})
+	sut.AddData([][]any{{"b"}, {"c"}}, "table", "id", 0)
+	sut.AddData([][]any{{"d"}}, "table", "id", 0)
+
+	// Retained columns keep every generated value, as well as a sample.
+	assert.Equal(t, []any{"b", "c", "d"}, sut.GetValues("table.id"))
+	assert.Equal(t, []any{"b", "c", "d"}, sut.GetGeneratedValues("table.id"))
+	assert.Equal(t, []any{"a", "b", "c", "d"}, sut.GetAllValues("table.id"))
+
+	// Every generated value survives a snapshot.
+	resumed := NewIterationData()
+	resumed.Retain("table.id")
+	resumed.Load(sut.Snapshot(func(string) bool { return true }))
+	assert.Equal(t, []any{"b", "c", "d"}, resumed.GetGeneratedValues("table.id"))
+}
@@ -0,0 +1,34 @@
+package model
+
+import "strings"
+
+// OutputMode defines where generated data will be written.
+type OutputMode string
+
+const (
+	OutputModeDatabase OutputMode = "database"
+	OutputModeCSV      OutputMode = "csv"
+	OutputModeParquet  OutputMode = "parquet"
+	OutputModeJSONL    OutputMode = "jsonl"
+	OutputModeSQL      OutputMode = "sql"
+	OutputModeInvalid  OutputMode = "INVALID"
+)
+
+// ParseOutputMode takes a string and returns the corresponding OutputMode
+// or invalid.
+func ParseOutputMode(raw string) OutputMode {
+	switch strings.ToLower(raw) {
+	case "database":
+		return OutputModeDatabase
+	case "csv":
+		return OutputModeCSV
+	case "parquet":
+		return OutputModeParquet
+	case "jsonl":
+		return OutputModeJSONL
+	case "sql":
+		return OutputModeSQL
+	default:
+		return OutputModeInvalid
+	}
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// Series configures a table whose rows are readings over time, such as
+// sensor measurements or metrics. Its timestamp column advances from a
+// start time by an interval, and its value columns change gradually from
+// one reading to the next, instead of being independent random draws.
+type Series struct {
+	// Timestamp is the column that holds the time of each reading.
+	Timestamp string `yaml:"timestamp"`
+
+	// Start is the time of the first reading.
+	Start time.Time `yaml:"start"`
+
+	// Interval is the time between consecutive readings.
+	Interval time.Duration `yaml:"interval"`
+
+	// Jitter is the largest random delay added to each reading's time,
+	// which must be less than the interval, so that times always increase.
+	Jitter time.Duration `yaml:"jitter,omitempty"`
+
+	// Format is the Go time layout that timestamps are written with
+	// (defaults to RFC 3339).
+	Format string `yaml:"format,omitempty"`
+
+	// Per is a column in the same row, for each of whose values there's a
+	// separate series, such as one per device_id.
+	Per string `yaml:"per,omitempty"`
+
+	// Values are the columns whose values follow the series.
+	Values []SeriesValue `yaml:"values,omitempty"`
+
+	// Existing holds the positions of the series that earlier rows have
+	// already started, keyed by their per column value, which new rows
+	// continue from.
+	Existing map[string]SeriesPosition `yaml:"-"`
+}
+
+// SeriesPosition is how far a single series has got: the number of
+// readings taken, and the current offset of each value's random walk.
+type SeriesPosition struct {
+	Readings int64     `json:"readings"`
+	Walks    []float64 `json:"walks,omitempty"`
+}
+
+// SeriesValue configures a column whose values are the sum of a starting
+// value, a trend, a seasonal cycle, and a random walk.
+type SeriesValue struct {
+	Column string `yaml:"column"`
+
+	// Start is the value of the first reading.
+	Start float64 `yaml:"start"`
+
+	// Walk is the largest random change between consecutive readings.
+	Walk float64 `yaml:"walk,omitempty"`
+
+	// Trend is the change in value per interval.
+	Trend float64 `yaml:"trend,omitempty"`
+
+	// Amplitude and Period describe a sine wave added to the value, such
+	// as a daily cycle.
+	Amplitude float64       `yaml:"amplitude,omitempty"`
+	Period    time.Duration `yaml:"period,omitempty"`
+
+	// Min and Max optionally bound the value.
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+}
+
+// Columns returns the names of the columns computed from the series.
+func (s *Series) Columns() []string {
+	values := lo.Map(s.Values, func(v SeriesValue, _ int) string { return v.Column })
+	return append([]string{s.Timestamp}, values...)
+}
+
+// Computes returns true if a column is computed from the series.
+func (s *Series) Computes(column string) bool {
+	return lo.Contains(s.Columns(), column)
+}
+
+// prepareSeries applies a table's series defaults, validates it, and adds
+// any computed columns that aren't already in the table.
+func prepareSeries(table *Table) error {
+	s := table.Series
+	if s == nil {
+		return nil
+	}
+
+	if table.Tree != nil || table.Edges != nil {
+		return fmt.Errorf("a series table can't also have a tree or edges")
+	}
+
+	if s.Timestamp == "" {
+		return fmt.Errorf("missing series timestamp column")
+	}
+
+	if s.Start.IsZero() {
+		return fmt.Errorf("missing series start time")
+	}
+
+	if s.Interval <= 0 {
+		return fmt.Errorf("invalid series interval: %s", s.Interval)
+	}
+
+	if s.Jitter < 0 || s.Jitter >= s.Interval {
+		return fmt.Errorf("series jitter %s must be between 0 and the interval %s", s.Jitter, s.Interval)
+	}
+
+	s.Format = lo.Ternary(s.Format != "", s.Format, time.RFC3339)
+
+	if dups := lo.FindDuplicates(s.Columns()); len(dups) > 0 {
+		return fmt.Errorf("series column %q is computed more than once", dups[0])
+	}
+
+	for _, v := range s.Values {
+		if v.Column == "" {
+			return fmt.Errorf("missing series value column")
+		}
+
+		if v.Amplitude != 0 && v.Period <= 0 {
+			return fmt.Errorf("series value %q has an amplitude but no period", v.Column)
+		}
+
+		if v.Min != nil && v.Max != nil && *v.Max < *v.Min {
+			return fmt.Errorf("series value %q has a max %v less than its min %v", v.Column, *v.Max, *v.Min)
+		}
+	}
+
+	names := lo.Map(table.Columns, func(c Column, _ int) string { return c.Name })
+	if s.Per != "" && (!lo.Contains(names, s.Per) || s.Computes(s.Per)) {
+		return fmt.Errorf("missing series per column: %q", s.Per)
+	}
+
+	for _, name := range s.Columns() {
+		if !lo.Contains(names, name) {
+			table.Columns = append(table.Columns, Column{Name: name})
+		}
+	}
+
+	return nil
+}
+
+// Clamp bounds a value by the value's min and max.
+func (v SeriesValue) Clamp(x float64) float64 {
+	if v.Min != nil && x < *v.Min {
+		return *v.Min
+	}
+
+	if v.Max != nil && x > *v.Max {
+		return *v.Max
+	}
+
+	return x
+}
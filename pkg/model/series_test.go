@@ -0,0 +1,121 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrepareSeries(t *testing.T) {
+	table := Table{
+		Name: "reading",
+		Series: &Series{
+			Timestamp: "recorded_at",
+			Start:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Interval:  time.Minute,
+			Per:       "device_id",
+			Values:    []SeriesValue{{Column: "temperature", Start: 20}},
+		},
+		Columns: []Column{
+			{Name: "device_id", Ref: "device.id"},
+			{Name: "temperature"},
+		},
+	}
+
+	assert.NoError(t, prepareSeries(&table))
+
+	assert.Equal(t, time.RFC3339, table.Series.Format)
+	assert.Equal(t, []string{"device_id", "temperature", "recorded_at"}, lo.Map(table.Columns, func(c Column, _ int) string { return c.Name }))
+
+	for i := range table.Columns {
+		assert.NoError(t, parseColumn(&table, i))
+	}
+	assert.Equal(t, []ColumnType{ColumnTypeRef, ColumnTypeSeries, ColumnTypeSeries}, lo.Map(table.Columns, func(c Column, _ int) ColumnType { return c.Mode }))
+}
+
+func TestPrepareSeries_Invalid(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	min, max := 10.0, 0.0
+
+	cases := []struct {
+		name     string
+		table    Table
+		expError string
+	}{
+		{
+			name:     "tree",
+			table:    Table{Tree: &Tree{}, Series: &Series{}},
+			expError: "a series table can't also have a tree or edges",
+		},
+		{
+			name:     "missing timestamp",
+			table:    Table{Series: &Series{Start: start, Interval: time.Minute}},
+			expError: "missing series timestamp column",
+		},
+		{
+			name:     "missing start",
+			table:    Table{Series: &Series{Timestamp: "ts", Interval: time.Minute}},
+			expError: "missing series start time",
+		},
+		{
+			name:     "invalid interval",
+			table:    Table{Series: &Series{Timestamp: "ts", Start: start}},
+			expError: "invalid series interval: 0s",
+		},
+		{
+			name:     "jitter too large",
+			table:    Table{Series: &Series{Timestamp: "ts", Start: start, Interval: time.Minute, Jitter: time.Minute}},
+			expError: "series jitter 1m0s must be between 0 and the interval 1m0s",
+		},
+		{
+			name:     "duplicate column",
+			table:    Table{Series: &Series{Timestamp: "ts", Start: start, Interval: time.Minute, Values: []SeriesValue{{Column: "ts"}}}},
+			expError: `series column "ts" is computed more than once`,
+		},
+		{
+			name:     "amplitude without period",
+			table:    Table{Series: &Series{Timestamp: "ts", Start: start, Interval: time.Minute, Values: []SeriesValue{{Column: "v", Amplitude: 1}}}},
+			expError: `series value "v" has an amplitude but no period`,
+		},
+		{
+			name:     "max less than min",
+			table:    Table{Series: &Series{Timestamp: "ts", Start: start, Interval: time.Minute, Values: []SeriesValue{{Column: "v", Min: &min, Max: &max}}}},
+			expError: `series value "v" has a max 0 less than its min 10`,
+		},
+		{
+			name:     "missing per",
+			table:    Table{Series: &Series{Timestamp: "ts", Start: start, Interval: time.Minute, Per: "device_id"}},
+			expError: `missing series per column: "device_id"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.EqualError(t, prepareSeries(&c.table), c.expError)
+		})
+	}
+}
+
+func TestSeriesValue_Clamp(t *testing.T) {
+	min, max := 0.0, 100.0
+
+	cases := []struct {
+		name  string
+		value SeriesValue
+		x     float64
+		exp   float64
+	}{
+		{name: "unbounded", value: SeriesValue{}, x: -5, exp: -5},
+		{name: "below min", value: SeriesValue{Min: &min, Max: &max}, x: -5, exp: 0},
+		{name: "above max", value: SeriesValue{Min: &min, Max: &max}, x: 105, exp: 100},
+		{name: "within bounds", value: SeriesValue{Min: &min, Max: &max}, x: 50, exp: 50},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, c.value.Clamp(c.x))
+		})
+	}
+}
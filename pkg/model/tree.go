@@ -0,0 +1,167 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/samber/lo"
+)
+
+// Tree configures a table whose rows form a hierarchy, such as categories,
+// org charts, or comment threads.
+type Tree struct {
+	// Key is the column that identifies each row, which the parent column
+	// references (defaults to "id").
+	Key string `yaml:"key,omitempty"`
+
+	// Parent is the column that references each row's parent, which is
+	// NULL for roots.
+	Parent string `yaml:"parent"`
+
+	// MaxDepth is the maximum number of levels in each tree, including the
+	// root (unlimited if 0).
+	MaxDepth int `yaml:"max_depth,omitempty"`
+
+	// Children is the range of the number of children that each row has,
+	// unless it's at the maximum depth (defaults to 1 to 5).
+	Children IntRange `yaml:"children,omitempty"`
+
+	// Optional columns computed from the shape of the tree.
+	Path          string `yaml:"path,omitempty"`
+	PathSeparator string `yaml:"path_separator,omitempty"`
+	Depth         string `yaml:"depth,omitempty"`
+	Left          string `yaml:"left,omitempty"`
+	Right         string `yaml:"right,omitempty"`
+}
+
+// TreeNode describes the position of a single row in a tree.
+type TreeNode struct {
+	// Parent is the index of the row's parent, or -1 for roots.
+	Parent int
+
+	// Depth is the row's level in the tree, starting from 1 for roots.
+	Depth int
+
+	// Left and Right are the row's nested set bounds.
+	Left  int64
+	Right int64
+}
+
+// Columns returns the names of the columns computed from the tree.
+func (t *Tree) Columns() []string {
+	return lo.Compact([]string{t.Parent, t.Path, t.Depth, t.Left, t.Right})
+}
+
+// Computes returns true if a column is computed from the tree.
+func (t *Tree) Computes(column string) bool {
+	return lo.Contains(t.Columns(), column)
+}
+
+// prepareTree applies a table's tree defaults, and adds any computed
+// columns that aren't already in the table.
+func prepareTree(table *Table) error {
+	t := table.Tree
+	if t == nil {
+		return nil
+	}
+
+	if t.Parent == "" {
+		return fmt.Errorf("missing tree parent column")
+	}
+
+	t.Key = lo.Ternary(t.Key != "", t.Key, "id")
+	t.PathSeparator = lo.Ternary(t.PathSeparator != "", t.PathSeparator, "/")
+
+	if t.Children == (IntRange{}) {
+		t.Children = IntRange{Min: 1, Max: 5}
+	}
+
+	if t.Children.Min < 0 || t.Children.Max < t.Children.Min {
+		return fmt.Errorf("invalid tree children range: %d to %d", t.Children.Min, t.Children.Max)
+	}
+
+	names := lo.Map(table.Columns, func(c Column, _ int) string { return c.Name })
+	if !lo.Contains(names, t.Key) {
+		return fmt.Errorf("missing tree key column: %q", t.Key)
+	}
+
+	for _, name := range t.Columns() {
+		if !lo.Contains(names, name) {
+			table.Columns = append(table.Columns, Column{Name: name})
+		}
+	}
+
+	return nil
+}
+
+// BuildTree returns the shape of a forest of n rows, in breadth-first
+// order, so that every row appears after its parent. Rows are added to a
+// single tree until it reaches its maximum depth, at which point another
+// tree is started.
+func BuildTree(t Tree, n int) []TreeNode {
+	nodes := make([]TreeNode, 0, n)
+	children := make([][]int, 0, n)
+
+	add := func(parent, depth int) {
+		nodes = append(nodes, TreeNode{Parent: parent, Depth: depth})
+		children = append(children, nil)
+		if parent >= 0 {
+			children[parent] = append(children[parent], len(nodes)-1)
+		}
+	}
+
+	for next := 0; len(nodes) < n; next++ {
+		// Start a new tree once every row in the current ones has had the
+		// chance to have children.
+		if next == len(nodes) {
+			add(-1, 1)
+		}
+
+		if t.MaxDepth > 0 && nodes[next].Depth >= t.MaxDepth {
+			continue
+		}
+
+		count := random.Int(t.Children.Min, t.Children.Max+1)
+		for i := int64(0); i < count && len(nodes) < n; i++ {
+			add(next, nodes[next].Depth+1)
+		}
+	}
+
+	numberNestedSets(nodes, children)
+	return nodes
+}
+
+// numberNestedSets assigns each node's left and right bounds, with a depth
+// first walk of each tree.
+func numberNestedSets(nodes []TreeNode, children [][]int) {
+	var counter int64
+
+	type frame struct {
+		node    int
+		visited bool
+	}
+
+	for root := range nodes {
+		if nodes[root].Parent != -1 {
+			continue
+		}
+
+		stack := []frame{{node: root}}
+		for len(stack) > 0 {
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			counter++
+			if f.visited {
+				nodes[f.node].Right = counter
+				continue
+			}
+
+			nodes[f.node].Left = counter
+			stack = append(stack, frame{node: f.node, visited: true})
+			for i := len(children[f.node]) - 1; i >= 0; i-- {
+				stack = append(stack, frame{node: children[f.node][i]})
+			}
+		}
+	}
+}
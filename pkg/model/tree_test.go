@@ -0,0 +1,134 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTree(t *testing.T) {
+	cases := []struct {
+		name     string
+		tree     Tree
+		rows     int
+		expRoots int
+		expDepth int
+	}{
+		{
+			name:     "single root",
+			tree:     Tree{Children: IntRange{Min: 2, Max: 2}},
+			rows:     15,
+			expRoots: 1,
+			expDepth: 4,
+		},
+		{
+			name:     "max depth starts new trees",
+			tree:     Tree{MaxDepth: 2, Children: IntRange{Min: 3, Max: 3}},
+			rows:     12,
+			expRoots: 3,
+			expDepth: 2,
+		},
+		{
+			name:     "leaves only",
+			tree:     Tree{Children: IntRange{Min: 0, Max: 0}},
+			rows:     5,
+			expRoots: 5,
+			expDepth: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			nodes := BuildTree(c.tree, c.rows)
+			assert.Len(t, nodes, c.rows)
+
+			roots := lo.CountBy(nodes, func(n TreeNode) bool { return n.Parent == -1 })
+			assert.Equal(t, c.expRoots, roots)
+
+			depth := lo.MaxBy(nodes, func(a, b TreeNode) bool { return a.Depth > b.Depth }).Depth
+			assert.Equal(t, c.expDepth, depth)
+
+			for i, n := range nodes {
+				if n.Parent == -1 {
+					assert.Equal(t, 1, n.Depth)
+					continue
+				}
+
+				// Parents come first, and contain their children.
+				parent := nodes[n.Parent]
+				assert.Less(t, n.Parent, i)
+				assert.Equal(t, parent.Depth+1, n.Depth)
+				assert.Greater(t, n.Left, parent.Left)
+				assert.Less(t, n.Right, parent.Right)
+			}
+		})
+	}
+}
+
+func TestBuildTree_NestedSets(t *testing.T) {
+	// A root with two children, the first of which has a child.
+	nodes := BuildTree(Tree{MaxDepth: 3, Children: IntRange{Min: 2, Max: 2}}, 4)
+
+	exp := []TreeNode{
+		{Parent: -1, Depth: 1, Left: 1, Right: 8},
+		{Parent: 0, Depth: 2, Left: 2, Right: 5},
+		{Parent: 0, Depth: 2, Left: 6, Right: 7},
+		{Parent: 1, Depth: 3, Left: 3, Right: 4},
+	}
+
+	assert.Equal(t, exp, nodes)
+}
+
+func TestPrepareTree(t *testing.T) {
+	table := Table{
+		Name: "category",
+		Tree: &Tree{Parent: "parent_id", Path: "path"},
+		Columns: []Column{
+			{Name: "id", Inc: 1},
+			{Name: "parent_id"},
+		},
+	}
+
+	assert.NoError(t, prepareTree(&table))
+
+	assert.Equal(t, "id", table.Tree.Key)
+	assert.Equal(t, "/", table.Tree.PathSeparator)
+	assert.Equal(t, IntRange{Min: 1, Max: 5}, table.Tree.Children)
+	assert.Equal(t, []string{"id", "parent_id", "path"}, lo.Map(table.Columns, func(c Column, _ int) string { return c.Name }))
+
+	for i := range table.Columns {
+		assert.NoError(t, parseColumn(&table, i))
+	}
+	assert.Equal(t, []ColumnType{ColumnTypeInc, ColumnTypeTree, ColumnTypeTree}, lo.Map(table.Columns, func(c Column, _ int) ColumnType { return c.Mode }))
+}
+
+func TestPrepareTree_Invalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		table    Table
+		expError string
+	}{
+		{
+			name:     "missing parent",
+			table:    Table{Tree: &Tree{}, Columns: []Column{{Name: "id"}}},
+			expError: "missing tree parent column",
+		},
+		{
+			name:     "missing key",
+			table:    Table{Tree: &Tree{Parent: "parent_id"}, Columns: []Column{{Name: "uuid"}}},
+			expError: `missing tree key column: "id"`,
+		},
+		{
+			name:     "invalid children",
+			table:    Table{Tree: &Tree{Parent: "parent_id", Children: IntRange{Min: 5, Max: 1}}, Columns: []Column{{Name: "id"}}},
+			expError: "invalid tree children range: 5 to 1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.EqualError(t, prepareTree(&c.table), c.expError)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// FormatValue converts a generated value into the text representation
+// that Postgres and CockroachDB accept when loading delimited files.
+func FormatValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case []byte:
+		return `\x` + hex.EncodeToString(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case time.Duration:
+		return x.String()
+	case pgtype.Array[any]:
+		return formatArray(x.Elements)
+	case fmt.Stringer:
+		return x.String()
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func formatArray(elements []any) string {
+	items := make([]string, len(elements))
+	for i, e := range elements {
+		if e == nil {
+			items[i] = "NULL"
+			continue
+		}
+
+		s := FormatValue(e)
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+		items[i] = `"` + s + `"`
+	}
+
+	return "{" + strings.Join(items, ",") + "}"
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatValue(t *testing.T) {
+	cases := []struct {
+		name  string
+		value any
+		exp   string
+	}{
+		{name: "nil", value: nil, exp: ""},
+		{name: "string", value: "a", exp: "a"},
+		{name: "int", value: int64(1), exp: "1"},
+		{name: "float", value: 1.5, exp: "1.5"},
+		{name: "bool", value: true, exp: "true"},
+		{name: "bytes", value: []byte{0xde, 0xad}, exp: `\xdead`},
+		{name: "duration", value: time.Minute * 90, exp: "1h30m0s"},
+		{name: "point", value: Point{Lat: 1, Lon: 2}, exp: "Point(2.000000 1.000000)"},
+		{
+			name:  "array",
+			value: pgtype.Array[any]{Elements: []any{"a", `b"c`, nil}},
+			exp:   `{"a","b\"c",NULL}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, FormatValue(c.value))
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package output
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/samber/lo"
+)
+
+// CSVWriter writes one CSV file per table into a directory, each starting
+// with a header row of column names.
+type CSVWriter struct {
+	dir string
+
+	filesMu sync.Mutex
+	files   map[string]*csvFile
+}
+
+type csvFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewCSVWriter returns a pointer to a new instance of CSVWriter, creating
+// the output directory if it doesn't already exist.
+func NewCSVWriter(dir string) (*CSVWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	return &CSVWriter{
+		dir:   dir,
+		files: map[string]*csvFile{},
+	}, nil
+}
+
+// Write appends a batch of rows to the table's CSV file.
+func (w *CSVWriter) Write(ctx context.Context, table model.Table, rows [][]any) error {
+	f, err := w.file(table)
+	if err != nil {
+		return fmt.Errorf("opening csv file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record := make([]string, len(table.Columns))
+	for _, row := range rows {
+		for i, v := range row {
+			record[i] = model.FormatValue(v)
+		}
+
+		if err = f.writer.Write(record); err != nil {
+			return fmt.Errorf("writing record: %w", err)
+		}
+	}
+
+	f.writer.Flush()
+	if err = f.writer.Error(); err != nil {
+		return fmt.Errorf("flushing records: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes and closes every file that has been written to.
+func (w *CSVWriter) Close() error {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	for name, f := range w.files {
+		f.writer.Flush()
+		if err := f.writer.Error(); err != nil {
+			return fmt.Errorf("flushing %q: %w", name, err)
+		}
+
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("closing %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *CSVWriter) file(table model.Table) (*csvFile, error) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	if f, ok := w.files[table.Name]; ok {
+		return f, nil
+	}
+
+	file, err := os.Create(filepath.Join(w.dir, table.Name+".csv"))
+	if err != nil {
+		return nil, fmt.Errorf("creating file: %w", err)
+	}
+
+	f := &csvFile{
+		file:   file,
+		writer: csv.NewWriter(file),
+	}
+
+	header := lo.Map(table.Columns, func(c model.Column, _ int) string {
+		return c.Name
+	})
+
+	if err = f.writer.Write(header); err != nil {
+		return nil, fmt.Errorf("writing header: %w", err)
+	}
+
+	w.files[table.Name] = f
+	return f, nil
+}
@@ -0,0 +1,34 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	sut, err := NewCSVWriter(dir)
+	assert.NoError(t, err)
+
+	table := model.Table{
+		Name: "person",
+		Columns: []model.Column{
+			{Name: "id"},
+			{Name: "name"},
+		},
+	}
+
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{{int64(1), "a"}, {int64(2), "b, c"}}))
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{{int64(3), nil}}))
+	assert.NoError(t, sut.Close())
+
+	act, err := os.ReadFile(filepath.Join(dir, "person.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "id,name\n1,a\n2,\"b, c\"\n3,\n", string(act))
+}
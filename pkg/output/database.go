@@ -0,0 +1,180 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/query"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/samber/lo"
+)
+
+// DatabaseWriter writes rows directly to a database.
+type DatabaseWriter struct {
+	db         *pgxpool.Pool
+	logger     zerolog.Logger
+	insertMode model.InsertMode
+	timeout    time.Duration
+}
+
+// NewDatabaseWriter returns a pointer to a new instance of DatabaseWriter.
+// Each insert statement is cancelled if it runs for longer than timeout,
+// unless timeout is zero.
+func NewDatabaseWriter(db *pgxpool.Pool, logger zerolog.Logger, insertMode model.InsertMode, timeout time.Duration) *DatabaseWriter {
+	return &DatabaseWriter{
+		db:         db,
+		logger:     logger,
+		insertMode: insertMode,
+		timeout:    timeout,
+	}
+}
+
+// Write inserts a batch of rows into a table using a single statement.
+func (w *DatabaseWriter) Write(ctx context.Context, table model.Table, rows [][]any) error {
+	stmt, err := query.BuildInsert(table, rows, w.insertMode)
+	if err != nil {
+		return fmt.Errorf("building insert: %w", err)
+	}
+	w.logger.Debug().Str("stmt", stmt).Msg("running insert")
+
+	return w.withConn(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		if _, err := conn.Exec(ctx, stmt, lo.Flatten(rows)...); err != nil {
+			return fmt.Errorf("executing query: %w", err)
+		}
+		return nil
+	})
+}
+
+// WriteReturning inserts a batch of rows like Write, returning the values
+// of the given columns for each row that was inserted. Under the conflict
+// insert mode, rows that conflict with existing rows aren't inserted, and
+// values are returned as strings, which can be written to columns of any
+// type.
+func (w *DatabaseWriter) WriteReturning(ctx context.Context, table model.Table, rows [][]any, columns []string) ([][]any, error) {
+	if w.insertMode != model.InsertModeConflict {
+		if err := w.Write(ctx, table, rows); err != nil {
+			return nil, err
+		}
+		return columnValues(table, rows, columns), nil
+	}
+
+	// Without any columns to return, only the number of inserted rows is
+	// needed.
+	if len(columns) == 0 {
+		stmt, err := query.BuildInsert(table, rows, w.insertMode)
+		if err != nil {
+			return nil, fmt.Errorf("building insert: %w", err)
+		}
+		w.logger.Debug().Str("stmt", stmt).Msg("running insert")
+
+		var values [][]any
+		err = w.withConn(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+			tag, err := conn.Exec(ctx, stmt, lo.Flatten(rows)...)
+			if err != nil {
+				return fmt.Errorf("executing query: %w", err)
+			}
+
+			values = make([][]any, tag.RowsAffected())
+			return nil
+		})
+
+		return values, err
+	}
+
+	stmt, err := query.BuildInsertReturning(table, rows, w.insertMode, columns)
+	if err != nil {
+		return nil, fmt.Errorf("building insert: %w", err)
+	}
+	w.logger.Debug().Str("stmt", stmt).Msg("running insert")
+
+	var values [][]any
+	err = w.withConn(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		result, err := conn.Query(ctx, stmt, lo.Flatten(rows)...)
+		if err != nil {
+			return fmt.Errorf("executing query: %w", err)
+		}
+
+		if values, err = pgx.CollectRows(result, func(row pgx.CollectableRow) ([]any, error) {
+			return row.Values()
+		}); err != nil {
+			return fmt.Errorf("reading inserted rows: %w", err)
+		}
+
+		return nil
+	})
+
+	return values, err
+}
+
+// columnValues returns the values of the given columns for each row.
+func columnValues(table model.Table, rows [][]any, columns []string) [][]any {
+	names := lo.Map(table.Columns, func(c model.Column, _ int) string { return c.Name })
+	indexes := lo.Map(columns, func(c string, _ int) int { return lo.IndexOf(names, c) })
+
+	return lo.Map(rows, func(row []any, _ int) []any {
+		return lo.Map(indexes, func(i int, _ int) any { return row[i] })
+	})
+}
+
+// Update fills in a deferred column in the rows with the given keys, one
+// batch of rows at a time. Only the rows that were written by this run are
+// updated, leaving any existing rows as they were.
+func (w *DatabaseWriter) Update(ctx context.Context, table model.Table, column, key string, keys []any, batch int, value func() any) (int, error) {
+	var updated int
+	stmt := query.BuildUpdate(table.Name, column, key)
+
+	for _, chunk := range lo.Chunk(keys, batch) {
+		b := &pgx.Batch{}
+		for _, k := range chunk {
+			v := value()
+			if v == nil {
+				return updated, fmt.Errorf("no values to set %s.%s to", table.Name, column)
+			}
+			b.Queue(stmt, v, k)
+		}
+
+		if err := w.sendBatch(ctx, b); err != nil {
+			return updated, fmt.Errorf("updating rows: %w", err)
+		}
+
+		updated += len(chunk)
+		w.logger.Debug().Str("table", table.Name).Str("column", column).Int("updated", updated).Msg("updated rows")
+	}
+
+	return updated, nil
+}
+
+func (w *DatabaseWriter) sendBatch(ctx context.Context, b *pgx.Batch) error {
+	return w.withConn(ctx, func(ctx context.Context, conn *pgxpool.Conn) error {
+		return conn.SendBatch(ctx, b).Close()
+	})
+}
+
+// withConn runs fn with a connection from the pool, cancelling it if it
+// runs for longer than the writer's timeout. The timeout starts once the
+// connection has been acquired, so that waiting for a busy pool doesn't
+// count towards it.
+func (w *DatabaseWriter) withConn(ctx context.Context, fn func(context.Context, *pgxpool.Conn) error) error {
+	conn, err := w.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+
+	return fn(ctx, conn)
+}
+
+// Close is a no-op, as the connection pool is owned by the caller.
+func (w *DatabaseWriter) Close() error {
+	return nil
+}
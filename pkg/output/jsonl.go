@@ -0,0 +1,322 @@
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/samber/lo"
+)
+
+// JSONLWriter writes one newline-delimited JSON file per table into a
+// directory, with one document per row.
+//
+// Tables can optionally be embedded into the tables they reference, in
+// which case child rows are written as an array inside their parent's
+// document, rather than into a file of their own. As parents can only be
+// written once all of their children exist, tables involved in an embed
+// are held in memory until the writer is closed.
+type JSONLWriter struct {
+	dir    string
+	embeds []Embed
+
+	filesMu sync.Mutex
+	files   map[string]*jsonlFile
+
+	// Documents for tables involved in an embed.
+	docsMu   sync.Mutex
+	parents  map[string][]*document
+	children map[Embed]map[string][]*document
+}
+
+// Embed describes a child table whose rows are nested inside the parent
+// rows that they reference.
+type Embed struct {
+	Child        string
+	ChildColumn  string
+	Parent       string
+	ParentColumn string
+}
+
+// ParseEmbed parses an embed in the form child_table.ref_column, where
+// ref_column is a ref column that points to the parent table.
+func ParseEmbed(raw string, tables []model.Table) (Embed, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 2 {
+		return Embed{}, fmt.Errorf("expected embed in the form table.column: %q", raw)
+	}
+
+	child, ok := lo.Find(tables, func(t model.Table) bool {
+		return t.Name == parts[0]
+	})
+	if !ok {
+		return Embed{}, fmt.Errorf("missing table: %q", parts[0])
+	}
+
+	column, ok := lo.Find(child.Columns, func(c model.Column) bool {
+		return c.Name == parts[1]
+	})
+	if !ok || column.Ref == "" {
+		return Embed{}, fmt.Errorf("missing ref column: %q", raw)
+	}
+
+	refParts := strings.Split(column.Ref, ".")
+	if len(refParts) != 2 {
+		return Embed{}, fmt.Errorf("invalid ref: %q", column.Ref)
+	}
+
+	return Embed{
+		Child:        child.Name,
+		ChildColumn:  column.Name,
+		Parent:       refParts[0],
+		ParentColumn: refParts[1],
+	}, nil
+}
+
+type jsonlFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewJSONLWriter returns a pointer to a new instance of JSONLWriter,
+// creating the output directory if it doesn't already exist.
+func NewJSONLWriter(dir string, embeds []Embed) (*JSONLWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	children := map[Embed]map[string][]*document{}
+	for _, e := range embeds {
+		children[e] = map[string][]*document{}
+	}
+
+	return &JSONLWriter{
+		dir:      dir,
+		embeds:   embeds,
+		files:    map[string]*jsonlFile{},
+		parents:  map[string][]*document{},
+		children: children,
+	}, nil
+}
+
+// Write appends a batch of rows to the table's JSONL file, or holds them
+// in memory if the table is involved in an embed.
+func (w *JSONLWriter) Write(ctx context.Context, table model.Table, rows [][]any) error {
+	docs := make([]*document, len(rows))
+	for i, row := range rows {
+		docs[i] = newDocument(table, row)
+	}
+
+	if w.buffered(table.Name) {
+		w.buffer(table.Name, docs)
+		return nil
+	}
+
+	return w.writeDocuments(table.Name, docs)
+}
+
+// Close nests embedded documents inside their parents, writes all held
+// documents, and closes every file that has been written to.
+func (w *JSONLWriter) Close() error {
+	w.docsMu.Lock()
+	defer w.docsMu.Unlock()
+
+	// Attach children to every parent. As documents are pointers, children
+	// that have children of their own are attached to them in-place.
+	for _, e := range w.embeds {
+		for _, parent := range w.parents[e.Parent] {
+			key := fmt.Sprint(parent.values[e.ParentColumn])
+			parent.set(e.Child, lo.Ternary(w.children[e][key] != nil, w.children[e][key], []*document{}))
+		}
+	}
+
+	for table, docs := range w.parents {
+		if w.embedded(table) {
+			continue
+		}
+
+		if err := w.writeDocuments(table, docs); err != nil {
+			return fmt.Errorf("writing %q: %w", table, err)
+		}
+	}
+
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	for name, f := range w.files {
+		if err := f.writer.Flush(); err != nil {
+			return fmt.Errorf("flushing %q: %w", name, err)
+		}
+
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("closing %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *JSONLWriter) buffer(table string, docs []*document) {
+	w.docsMu.Lock()
+	defer w.docsMu.Unlock()
+
+	for _, e := range w.embeds {
+		if e.Child != table {
+			continue
+		}
+
+		for _, doc := range docs {
+			key := fmt.Sprint(doc.values[e.ChildColumn])
+			w.children[e][key] = append(w.children[e][key], doc)
+		}
+	}
+
+	if lo.ContainsBy(w.embeds, func(e Embed) bool { return e.Parent == table }) || !w.embedded(table) {
+		w.parents[table] = append(w.parents[table], docs...)
+	}
+}
+
+// buffered returns true if a table's documents need to be held in memory.
+func (w *JSONLWriter) buffered(table string) bool {
+	return lo.ContainsBy(w.embeds, func(e Embed) bool {
+		return e.Parent == table || e.Child == table
+	})
+}
+
+// embedded returns true if a table's documents are written inside another
+// table's documents.
+func (w *JSONLWriter) embedded(table string) bool {
+	return lo.ContainsBy(w.embeds, func(e Embed) bool {
+		return e.Child == table
+	})
+}
+
+func (w *JSONLWriter) writeDocuments(table string, docs []*document) error {
+	f, err := w.file(table)
+	if err != nil {
+		return fmt.Errorf("opening jsonl file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, doc := range docs {
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return fmt.Errorf("marshalling document: %w", err)
+		}
+
+		if _, err = f.writer.Write(append(b, '\n')); err != nil {
+			return fmt.Errorf("writing document: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *JSONLWriter) file(table string) (*jsonlFile, error) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	if f, ok := w.files[table]; ok {
+		return f, nil
+	}
+
+	file, err := os.Create(filepath.Join(w.dir, table+".jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("creating file: %w", err)
+	}
+
+	f := &jsonlFile{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+
+	w.files[table] = f
+	return f, nil
+}
+
+// document is a JSON object that preserves the order of its keys.
+type document struct {
+	keys   []string
+	values map[string]any
+}
+
+func newDocument(table model.Table, row []any) *document {
+	doc := &document{
+		keys:   make([]string, 0, len(row)),
+		values: make(map[string]any, len(row)),
+	}
+
+	for i, v := range row {
+		doc.set(table.Columns[i].Name, jsonValue(v))
+	}
+
+	return doc
+}
+
+func (d *document) set(key string, value any) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+// MarshalJSON writes the document's values in the order they were set.
+func (d *document) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, k := range d.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, fmt.Errorf("marshalling key %q: %w", k, err)
+		}
+
+		value, err := json.Marshal(d.values[k])
+		if err != nil {
+			return nil, fmt.Errorf("marshalling value for %q: %w", k, err)
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonValue converts a generated value into something that marshals into
+// idiomatic JSON.
+func jsonValue(v any) any {
+	switch x := v.(type) {
+	case pgtype.Array[any]:
+		return lo.Map(x.Elements, func(e any, _ int) any {
+			return jsonValue(e)
+		})
+	case model.Point:
+		return map[string]any{
+			"type":        "Point",
+			"coordinates": []float64{x.Lon, x.Lat},
+		}
+	case time.Duration:
+		return x.String()
+	default:
+		return v
+	}
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEmbed(t *testing.T) {
+	tables := []model.Table{
+		{Name: "purchase", Columns: []model.Column{{Name: "id"}}},
+		{Name: "purchase_line", Columns: []model.Column{{Name: "purchase_id", Ref: "purchase.id"}, {Name: "sku"}}},
+	}
+
+	cases := []struct {
+		name   string
+		raw    string
+		exp    Embed
+		expErr bool
+	}{
+		{
+			name: "valid",
+			raw:  "purchase_line.purchase_id",
+			exp:  Embed{Child: "purchase_line", ChildColumn: "purchase_id", Parent: "purchase", ParentColumn: "id"},
+		},
+		{name: "missing column", raw: "purchase_line", expErr: true},
+		{name: "missing table", raw: "refund.purchase_id", expErr: true},
+		{name: "not a ref", raw: "purchase_line.sku", expErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			act, err := ParseEmbed(c.raw, tables)
+			if c.expErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, c.exp, act)
+		})
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	member := model.Table{Name: "member", Columns: []model.Column{{Name: "id"}}}
+	purchase := model.Table{Name: "purchase", Columns: []model.Column{{Name: "id"}, {Name: "member_id"}}}
+	line := model.Table{Name: "purchase_line", Columns: []model.Column{{Name: "purchase_id"}, {Name: "qty"}}}
+
+	dir := t.TempDir()
+	sut, err := NewJSONLWriter(dir, []Embed{
+		{Child: "purchase", ChildColumn: "member_id", Parent: "member", ParentColumn: "id"},
+		{Child: "purchase_line", ChildColumn: "purchase_id", Parent: "purchase", ParentColumn: "id"},
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, sut.Write(context.Background(), member, [][]any{{"m1"}, {"m2"}}))
+	assert.NoError(t, sut.Write(context.Background(), purchase, [][]any{{int64(1), "m1"}, {int64(2), "m1"}}))
+	assert.NoError(t, sut.Write(context.Background(), line, [][]any{{int64(1), 10}, {int64(1), 20}}))
+	assert.NoError(t, sut.Close())
+
+	act, err := os.ReadFile(filepath.Join(dir, "member.jsonl"))
+	assert.NoError(t, err)
+
+	exp := `{"id":"m1","purchase":[{"id":1,"member_id":"m1","purchase_line":[{"purchase_id":1,"qty":10},{"purchase_id":1,"qty":20}]},{"id":2,"member_id":"m1","purchase_line":[]}]}` + "\n" +
+		`{"id":"m2","purchase":[]}` + "\n"
+	assert.Equal(t, exp, string(act))
+
+	for _, name := range []string{"purchase.jsonl", "purchase_line.jsonl"} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		assert.True(t, os.IsNotExist(err))
+	}
+}
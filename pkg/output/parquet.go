@@ -0,0 +1,421 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/random"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/parquet-go/parquet-go"
+	"github.com/samber/lo"
+)
+
+// ParquetWriter writes one Parquet file per table into a directory. If
+// fileRows is set, each table is instead partitioned into a directory of
+// files containing no more than fileRows rows each.
+type ParquetWriter struct {
+	dir      string
+	tables   []model.Table
+	fileRows int
+
+	filesMu sync.Mutex
+	files   map[string]*parquetFile
+}
+
+type parquetFile struct {
+	mu         sync.Mutex
+	schema     *parquet.Schema
+	converters []converter
+	file       *os.File
+	writer     *parquet.Writer
+	rows       int
+	part       int
+}
+
+// converter turns a generated value into the Go type expected by the
+// Parquet schema for its column.
+type converter func(any) (any, error)
+
+// NewParquetWriter returns a pointer to a new instance of ParquetWriter,
+// creating the output directory if it doesn't already exist. All of the
+// config's tables are required to resolve the types of ref columns.
+func NewParquetWriter(dir string, tables []model.Table, fileRows int) (*ParquetWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	return &ParquetWriter{
+		dir:      dir,
+		tables:   tables,
+		fileRows: fileRows,
+		files:    map[string]*parquetFile{},
+	}, nil
+}
+
+// Write appends a batch of rows to the table's Parquet file, starting a new
+// partition whenever the current one is full.
+func (w *ParquetWriter) Write(ctx context.Context, table model.Table, rows [][]any) error {
+	f, err := w.file(table)
+	if err != nil {
+		return fmt.Errorf("opening parquet file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, row := range rows {
+		if f.writer == nil || (w.fileRows > 0 && f.rows >= w.fileRows) {
+			if err = w.rotate(table, f); err != nil {
+				return fmt.Errorf("starting new partition: %w", err)
+			}
+		}
+
+		record := make(map[string]any, len(row))
+		for i, v := range row {
+			if record[table.Columns[i].Name], err = f.converters[i](v); err != nil {
+				return fmt.Errorf("converting %q: %w", table.Columns[i].Name, err)
+			}
+		}
+
+		if err = f.writer.Write(record); err != nil {
+			return fmt.Errorf("writing row: %w", err)
+		}
+		f.rows++
+	}
+
+	return nil
+}
+
+// Close flushes and closes every file that has been written to.
+func (w *ParquetWriter) Close() error {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	for name, f := range w.files {
+		if err := f.close(); err != nil {
+			return fmt.Errorf("closing %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *ParquetWriter) file(table model.Table) (*parquetFile, error) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	if f, ok := w.files[table.Name]; ok {
+		return f, nil
+	}
+
+	schema, converters, err := w.schemaOf(table)
+	if err != nil {
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+
+	f := &parquetFile{
+		schema:     schema,
+		converters: converters,
+	}
+
+	w.files[table.Name] = f
+	return f, nil
+}
+
+func (w *ParquetWriter) rotate(table model.Table, f *parquetFile) error {
+	if err := f.close(); err != nil {
+		return fmt.Errorf("closing partition: %w", err)
+	}
+
+	path := filepath.Join(w.dir, table.Name+".parquet")
+	if w.fileRows > 0 {
+		partDir := filepath.Join(w.dir, table.Name)
+		if err := os.MkdirAll(partDir, 0755); err != nil {
+			return fmt.Errorf("creating partition directory: %w", err)
+		}
+		path = filepath.Join(partDir, fmt.Sprintf("part-%05d.parquet", f.part))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating file: %w", err)
+	}
+
+	f.file = file
+	f.writer = parquet.NewWriter(file, f.schema)
+	f.rows = 0
+	f.part++
+
+	return nil
+}
+
+func (f *parquetFile) close() error {
+	if f.writer == nil {
+		return nil
+	}
+
+	if err := f.writer.Close(); err != nil {
+		return fmt.Errorf("closing writer: %w", err)
+	}
+
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("closing file: %w", err)
+	}
+
+	f.writer = nil
+	return nil
+}
+
+func (w *ParquetWriter) schemaOf(table model.Table) (*parquet.Schema, []converter, error) {
+	group := parquet.Group{}
+	converters := make([]converter, len(table.Columns))
+
+	for i, c := range table.Columns {
+		node, conv, err := w.columnNode(table, c, 0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %q: %w", c.Name, err)
+		}
+
+		group[c.Name] = parquet.Optional(node)
+		converters[i] = conv
+	}
+
+	return parquet.NewSchema(table.Name, group), converters, nil
+}
+
+// columnNode derives the Parquet type of a table's column, including the
+// columns computed from the table's tree, series, or running totals.
+func (w *ParquetWriter) columnNode(table model.Table, c model.Column, depth int) (parquet.Node, converter, error) {
+	switch c.Mode {
+	case model.ColumnTypeSeries:
+		node, conv := seriesNode(table, c)
+		return node, conv, nil
+	case model.ColumnTypeTree:
+		c = treeColumn(table, c)
+	case model.ColumnTypeCumulative:
+		c = cumulativeColumn(table, c)
+	}
+
+	return w.nodeOf(c, depth)
+}
+
+// nodeOf derives the Parquet type of a column from its mode. The depth
+// argument guards against ref columns that reference each other.
+func (w *ParquetWriter) nodeOf(c model.Column, depth int) (parquet.Node, converter, error) {
+	switch c.Mode {
+	case model.ColumnTypeInc:
+		if c.NextID != nil && c.NextID.Props().Format != "" {
+			return parquet.String(), convertString, nil
+		}
+		return parquet.Int(64), convertInt, nil
+
+	case model.ColumnTypeSet, model.ColumnTypeUUIDv5:
+		return parquet.String(), convertString, nil
+
+	case model.ColumnTypeValue:
+		if v, ok := random.Replacements[c.Value]; ok {
+			node, conv := nodeOfValue(v())
+			return node, conv, nil
+		}
+		return parquet.String(), convertString, nil
+
+	case model.ColumnTypeArray:
+		v, ok := random.Replacements[c.Array]
+		if !ok {
+			return parquet.List(parquet.String()), convertList(convertString), nil
+		}
+
+		node, conv := nodeOfValue(v())
+		return parquet.List(node), convertList(conv), nil
+
+	case model.ColumnTypeRange:
+		return nodeOfRange(c)
+
+	case model.ColumnTypeRef:
+		table, ref, ok := w.findColumn(c.Ref)
+		if !ok || depth > len(w.tables) {
+			return parquet.String(), convertString, nil
+		}
+		return w.columnNode(table, ref, depth+1)
+
+	default:
+		return nil, nil, fmt.Errorf("invalid column mode: %q", c.Mode)
+	}
+}
+
+// treeColumn returns a column whose type matches that of a column computed
+// from a table's tree. Parent columns share the type of the key column,
+// while paths are strings and depths and nested set bounds are integers.
+func treeColumn(table model.Table, c model.Column) model.Column {
+	switch c.Name {
+	case table.Tree.Parent:
+		return model.Column{Name: c.Name, Mode: model.ColumnTypeRef, Ref: table.Name + "." + table.Tree.Key}
+	case table.Tree.Path:
+		return model.Column{Name: c.Name, Mode: model.ColumnTypeSet}
+	default:
+		return model.Column{Name: c.Name, Mode: model.ColumnTypeInc}
+	}
+}
+
+// cumulativeColumn returns a column whose type matches that of a running
+// total, which is the type of the column it totals, unless it starts from
+// a float or totals a series' values, which are floats.
+func cumulativeColumn(table model.Table, c model.Column) model.Column {
+	source, ok := lo.Find(table.Columns, func(s model.Column) bool { return s.Name == c.Cumulative })
+	if start := c.Total.Props().Start; !ok || source.Mode == model.ColumnTypeSeries || start != math.Trunc(start) {
+		return model.Column{Name: c.Name, Mode: model.ColumnTypeRange, Range: "float"}
+	}
+
+	source.Name = c.Name
+	return source
+}
+
+// seriesNode returns the Parquet type of a column computed from a table's
+// series. Timestamps are written as timestamps, and values as doubles.
+func seriesNode(table model.Table, c model.Column) (parquet.Node, converter) {
+	if c.Name == table.Series.Timestamp {
+		return parquet.Timestamp(parquet.Microsecond), convertTimestamp(table.Series.Format)
+	}
+
+	return parquet.Leaf(parquet.DoubleType), convertFloat
+}
+
+// findColumn returns a referenced column, along with its table.
+func (w *ParquetWriter) findColumn(ref string) (model.Table, model.Column, bool) {
+	parts := strings.Split(ref, ".")
+	if len(parts) != 2 {
+		return model.Table{}, model.Column{}, false
+	}
+
+	for _, t := range w.tables {
+		if t.Name != parts[0] {
+			continue
+		}
+
+		c, ok := lo.Find(t.Columns, func(c model.Column) bool {
+			return c.Name == parts[1]
+		})
+		return t, c, ok
+	}
+
+	return model.Table{}, model.Column{}, false
+}
+
+func nodeOfRange(c model.Column) (parquet.Node, converter, error) {
+	switch strings.ToLower(c.Range) {
+	case "int":
+		return parquet.Int(64), convertInt, nil
+
+	case "float":
+		return parquet.Leaf(parquet.DoubleType), convertFloat, nil
+
+	case "bytes":
+		return parquet.Leaf(parquet.ByteArrayType), convertAny, nil
+
+	case "timestamp":
+		var x model.TimestampRange
+		if err := c.Props.Unmarshal(&x); err != nil {
+			return nil, nil, fmt.Errorf("decoding timestamp range props: %w", err)
+		}
+		return parquet.Timestamp(parquet.Microsecond), convertTimestamp(x.Format), nil
+
+	default:
+		return parquet.String(), convertString, nil
+	}
+}
+
+// nodeOfValue derives a Parquet type from a sample of a generator's output.
+func nodeOfValue(sample any) (parquet.Node, converter) {
+	switch reflect.ValueOf(sample).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return parquet.Int(64), convertInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return parquet.Uint(64), convertUint
+	case reflect.Float32, reflect.Float64:
+		return parquet.Leaf(parquet.DoubleType), convertFloat
+	case reflect.Bool:
+		return parquet.Leaf(parquet.BooleanType), convertAny
+	default:
+		if _, ok := sample.(time.Time); ok {
+			return parquet.Timestamp(parquet.Microsecond), convertAny
+		}
+		return parquet.String(), convertString
+	}
+}
+
+func convertAny(v any) (any, error) {
+	return v, nil
+}
+
+func convertString(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return model.FormatValue(v), nil
+}
+
+func convertInt(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return reflect.ValueOf(v).Convert(reflect.TypeOf(int64(0))).Interface(), nil
+}
+
+func convertUint(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return reflect.ValueOf(v).Convert(reflect.TypeOf(uint64(0))).Interface(), nil
+}
+
+func convertFloat(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return reflect.ValueOf(v).Convert(reflect.TypeOf(float64(0))).Interface(), nil
+}
+
+// convertTimestamp parses timestamps back out of the formatted strings that
+// range: timestamp columns generate.
+func convertTimestamp(format string) converter {
+	return func(v any) (any, error) {
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+		return time.Parse(format, s)
+	}
+}
+
+func convertList(conv converter) converter {
+	return func(v any) (any, error) {
+		a, ok := v.(pgtype.Array[any])
+		if !ok {
+			return nil, nil
+		}
+
+		// List elements are required, so missing values are dropped.
+		elements := make([]any, 0, len(a.Elements))
+		for _, e := range a.Elements {
+			if e == nil {
+				continue
+			}
+
+			c, err := conv(e)
+			if err != nil {
+				return nil, fmt.Errorf("converting element: %w", err)
+			}
+			elements = append(elements, c)
+		}
+
+		return elements, nil
+	}
+}
@@ -0,0 +1,235 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParquetWriter(t *testing.T) {
+	props, err := model.NewRawMessage(model.TimestampRange{Format: "2006-01-02"})
+	assert.NoError(t, err)
+
+	table := model.Table{
+		Name: "person",
+		Columns: []model.Column{
+			{Name: "id", Mode: model.ColumnTypeInc},
+			{Name: "dob", Mode: model.ColumnTypeRange, Range: "timestamp", Props: props},
+			{Name: "fruits", Mode: model.ColumnTypeArray, Array: "${fruit}"},
+		},
+	}
+
+	dir := t.TempDir()
+	sut, err := NewParquetWriter(dir, []model.Table{table}, 0)
+	assert.NoError(t, err)
+
+	fruits := pgtype.Array[any]{Elements: []any{"apple", "pear"}}
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{{int64(1), "2024-01-02", fruits}}))
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{{int64(2), nil, nil}}))
+	assert.NoError(t, sut.Close())
+
+	type person struct {
+		ID     *int64    `parquet:"id,optional"`
+		DOB    time.Time `parquet:"dob,optional,timestamp(microsecond)"`
+		Fruits []string  `parquet:"fruits,optional,list"`
+	}
+
+	act, err := parquet.ReadFile[person](filepath.Join(dir, "person.parquet"))
+	assert.NoError(t, err)
+
+	dob := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []person{
+		{ID: ptr(int64(1)), DOB: dob, Fruits: []string{"apple", "pear"}},
+		{ID: ptr(int64(2))},
+	}, act)
+}
+
+func TestParquetWriter_Partitioned(t *testing.T) {
+	table := model.Table{
+		Name: "person",
+		Columns: []model.Column{
+			{Name: "id", Mode: model.ColumnTypeInc},
+		},
+	}
+
+	dir := t.TempDir()
+	sut, err := NewParquetWriter(dir, []model.Table{table}, 2)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{{int64(1)}, {int64(2)}, {int64(3)}}))
+	assert.NoError(t, sut.Close())
+
+	entries, err := os.ReadDir(filepath.Join(dir, "person"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestParquetWriter_Tree(t *testing.T) {
+	table := model.Table{
+		Name: "category",
+		Tree: &model.Tree{Key: "id", Parent: "parent_id", Path: "path", Depth: "depth"},
+		Columns: []model.Column{
+			{Name: "id", Mode: model.ColumnTypeInc},
+			{Name: "parent_id", Mode: model.ColumnTypeTree},
+			{Name: "path", Mode: model.ColumnTypeTree},
+			{Name: "depth", Mode: model.ColumnTypeTree},
+		},
+	}
+
+	dir := t.TempDir()
+	sut, err := NewParquetWriter(dir, []model.Table{table}, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{
+		{int64(1), nil, "1", int64(1)},
+		{int64(2), int64(1), "1/2", int64(2)},
+	}))
+	assert.NoError(t, sut.Close())
+
+	type category struct {
+		ID       *int64  `parquet:"id,optional"`
+		ParentID *int64  `parquet:"parent_id,optional"`
+		Path     *string `parquet:"path,optional"`
+		Depth    *int64  `parquet:"depth,optional"`
+	}
+
+	act, err := parquet.ReadFile[category](filepath.Join(dir, "category.parquet"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []category{
+		{ID: ptr(int64(1)), Path: ptr("1"), Depth: ptr(int64(1))},
+		{ID: ptr(int64(2)), ParentID: ptr(int64(1)), Path: ptr("1/2"), Depth: ptr(int64(2))},
+	}, act)
+}
+
+func TestParquetWriter_Stateful(t *testing.T) {
+	amount, err := model.NewRawMessage(model.IntRange{Min: 1, Max: 10})
+	assert.NoError(t, err)
+
+	table := model.Table{
+		Name:   "reading",
+		Series: &model.Series{Timestamp: "ts", Format: time.RFC3339, Values: []model.SeriesValue{{Column: "level"}}},
+		Columns: []model.Column{
+			{Name: "ts", Mode: model.ColumnTypeSeries},
+			{Name: "level", Mode: model.ColumnTypeSeries},
+			{Name: "amount", Mode: model.ColumnTypeRange, Range: "int", Props: amount},
+			{Name: "total", Mode: model.ColumnTypeCumulative, Cumulative: "amount", Total: model.NewRunningTotal(model.CumulativeProps{})},
+		},
+	}
+
+	dir := t.TempDir()
+	sut, err := NewParquetWriter(dir, []model.Table{table}, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sut.Write(context.Background(), table, [][]any{
+		{"2024-01-01T00:00:00Z", 1.5, int64(2), int64(2)},
+		{"2024-01-01T00:01:00Z", 2.5, int64(3), int64(5)},
+	}))
+	assert.NoError(t, sut.Close())
+
+	type reading struct {
+		TS     time.Time `parquet:"ts,optional,timestamp(microsecond)"`
+		Level  *float64  `parquet:"level,optional"`
+		Amount *int64    `parquet:"amount,optional"`
+		Total  *int64    `parquet:"total,optional"`
+	}
+
+	act, err := parquet.ReadFile[reading](filepath.Join(dir, "reading.parquet"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []reading{
+		{TS: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Level: ptr(1.5), Amount: ptr(int64(2)), Total: ptr(int64(2))},
+		{TS: time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC), Level: ptr(2.5), Amount: ptr(int64(3)), Total: ptr(int64(5))},
+	}, act)
+}
+
+func TestParquetWriter_RefToComputed(t *testing.T) {
+	category := model.Table{
+		Name: "category",
+		Tree: &model.Tree{Key: "id", Parent: "parent_id", Path: "path", Depth: "depth"},
+		Columns: []model.Column{
+			{Name: "id", Mode: model.ColumnTypeInc},
+			{Name: "parent_id", Mode: model.ColumnTypeTree},
+			{Name: "path", Mode: model.ColumnTypeTree},
+			{Name: "depth", Mode: model.ColumnTypeTree},
+		},
+	}
+
+	reading := model.Table{
+		Name:   "reading",
+		Series: &model.Series{Timestamp: "ts", Format: time.RFC3339, Values: []model.SeriesValue{{Column: "level"}}},
+		Columns: []model.Column{
+			{Name: "ts", Mode: model.ColumnTypeSeries},
+			{Name: "level", Mode: model.ColumnTypeSeries},
+		},
+	}
+
+	amount, err := model.NewRawMessage(model.IntRange{Min: 1, Max: 10})
+	assert.NoError(t, err)
+
+	account := model.Table{
+		Name: "account",
+		Columns: []model.Column{
+			{Name: "amount", Mode: model.ColumnTypeRange, Range: "int", Props: amount},
+			{Name: "balance", Mode: model.ColumnTypeCumulative, Cumulative: "amount", Total: model.NewRunningTotal(model.CumulativeProps{})},
+		},
+	}
+
+	product := model.Table{
+		Name: "product",
+		Columns: []model.Column{
+			{Name: "category_id", Mode: model.ColumnTypeRef, Ref: "category.id"},
+			{Name: "category_parent_id", Mode: model.ColumnTypeRef, Ref: "category.parent_id"},
+			{Name: "category_path", Mode: model.ColumnTypeRef, Ref: "category.path"},
+			{Name: "category_depth", Mode: model.ColumnTypeRef, Ref: "category.depth"},
+			{Name: "read_at", Mode: model.ColumnTypeRef, Ref: "reading.ts"},
+			{Name: "level", Mode: model.ColumnTypeRef, Ref: "reading.level"},
+			{Name: "balance", Mode: model.ColumnTypeRef, Ref: "account.balance"},
+		},
+	}
+
+	dir := t.TempDir()
+	sut, err := NewParquetWriter(dir, []model.Table{category, reading, account, product}, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sut.Write(context.Background(), product, [][]any{
+		{int64(2), int64(1), "1/2", int64(2), "2024-01-01T00:00:00Z", 1.5, int64(5)},
+	}))
+	assert.NoError(t, sut.Close())
+
+	type productRow struct {
+		CategoryID       *int64    `parquet:"category_id,optional"`
+		CategoryParentID *int64    `parquet:"category_parent_id,optional"`
+		CategoryPath     *string   `parquet:"category_path,optional"`
+		CategoryDepth    *int64    `parquet:"category_depth,optional"`
+		ReadAt           time.Time `parquet:"read_at,optional,timestamp(microsecond)"`
+		Level            *float64  `parquet:"level,optional"`
+		Balance          *int64    `parquet:"balance,optional"`
+	}
+
+	act, err := parquet.ReadFile[productRow](filepath.Join(dir, "product.parquet"))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []productRow{
+		{
+			CategoryID:       ptr(int64(2)),
+			CategoryParentID: ptr(int64(1)),
+			CategoryPath:     ptr("1/2"),
+			CategoryDepth:    ptr(int64(2)),
+			ReadAt:           time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Level:            ptr(1.5),
+			Balance:          ptr(int64(5)),
+		},
+	}, act)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
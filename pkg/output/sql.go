@@ -0,0 +1,118 @@
+package output
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/codingconcepts/dgs/pkg/query"
+)
+
+// SQLScriptName is the name of the file that SQLWriter writes to when
+// writing all tables into a single script.
+const SQLScriptName = "data.sql"
+
+// SQLWriter writes the insert statements that would have been run against
+// a database into SQL files, with values rendered as literals. Statements
+// are written to one file per table, or to a single script, in which they
+// appear in the order they were generated.
+type SQLWriter struct {
+	dir        string
+	singleFile bool
+	insertMode model.InsertMode
+
+	filesMu sync.Mutex
+	files   map[string]*sqlFile
+}
+
+type sqlFile struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// NewSQLWriter returns a pointer to a new instance of SQLWriter, creating
+// the output directory if it doesn't already exist.
+func NewSQLWriter(dir string, singleFile bool, insertMode model.InsertMode) (*SQLWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	return &SQLWriter{
+		dir:        dir,
+		singleFile: singleFile,
+		insertMode: insertMode,
+		files:      map[string]*sqlFile{},
+	}, nil
+}
+
+// Write appends a single insert statement for the batch of rows.
+func (w *SQLWriter) Write(ctx context.Context, table model.Table, rows [][]any) error {
+	stmt, err := query.BuildInsertLiteral(table, rows, w.insertMode)
+	if err != nil {
+		return fmt.Errorf("building insert: %w", err)
+	}
+
+	f, err := w.file(table)
+	if err != nil {
+		return fmt.Errorf("opening sql file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err = fmt.Fprintf(f.writer, "%s;\n", stmt); err != nil {
+		return fmt.Errorf("writing statement: %w", err)
+	}
+
+	return nil
+}
+
+// Close flushes and closes every file that has been written to.
+func (w *SQLWriter) Close() error {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	for name, f := range w.files {
+		if err := f.writer.Flush(); err != nil {
+			return fmt.Errorf("flushing %q: %w", name, err)
+		}
+
+		if err := f.file.Close(); err != nil {
+			return fmt.Errorf("closing %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (w *SQLWriter) file(table model.Table) (*sqlFile, error) {
+	w.filesMu.Lock()
+	defer w.filesMu.Unlock()
+
+	name := SQLScriptName
+	if !w.singleFile {
+		name = table.Name + ".sql"
+	}
+
+	if f, ok := w.files[name]; ok {
+		return f, nil
+	}
+
+	file, err := os.Create(filepath.Join(w.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("creating file: %w", err)
+	}
+
+	f := &sqlFile{
+		file:   file,
+		writer: bufio.NewWriter(file),
+	}
+
+	w.files[name] = f
+	return f, nil
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLWriter(t *testing.T) {
+	a := model.Table{Name: "a", Columns: []model.Column{{Name: "id"}}}
+	b := model.Table{Name: "b", Columns: []model.Column{{Name: "id"}, {Name: "a_id"}}}
+
+	cases := []struct {
+		name       string
+		singleFile bool
+		exp        map[string]string
+	}{
+		{
+			name: "file per table",
+			exp: map[string]string{
+				"a.sql": "INSERT INTO a (id) VALUES (1),(2);\n",
+				"b.sql": "INSERT INTO b (id,a_id) VALUES ('x',1);\n",
+			},
+		},
+		{
+			name:       "single file",
+			singleFile: true,
+			exp: map[string]string{
+				SQLScriptName: "INSERT INTO a (id) VALUES (1),(2);\nINSERT INTO b (id,a_id) VALUES ('x',1);\n",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			sut, err := NewSQLWriter(dir, c.singleFile, model.InsertModeInsert)
+			assert.NoError(t, err)
+
+			assert.NoError(t, sut.Write(context.Background(), a, [][]any{{1}, {2}}))
+			assert.NoError(t, sut.Write(context.Background(), b, [][]any{{"x", 1}}))
+			assert.NoError(t, sut.Close())
+
+			entries, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			assert.Len(t, entries, len(c.exp))
+
+			for name, exp := range c.exp {
+				act, err := os.ReadFile(filepath.Join(dir, name))
+				assert.NoError(t, err)
+				assert.Equal(t, exp, string(act))
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package output
+
+import (
+	"context"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+)
+
+// Writer persists batches of generated rows. Implementations must be safe
+// for concurrent use, as every worker shares the same Writer.
+type Writer interface {
+	// Write persists a batch of rows for a table. Rows are ordered to
+	// match the table's columns. Writes that take longer than the context
+	// allows may be abandoned.
+	Write(ctx context.Context, table model.Table, rows [][]any) error
+
+	// Close flushes anything buffered and releases any held resources.
+	Close() error
+}
+
+// Updater is implemented by writers that can fill in deferred columns once
+// every table has been written.
+type Updater interface {
+	// Update sets a column that was written as NULL to the values returned
+	// by value, in the rows whose key column has one of the given keys.
+	// Rows are updated in batches of up to batch rows. Update returns the
+	// number of rows updated.
+	Update(ctx context.Context, table model.Table, column, key string, keys []any, batch int, value func() any) (int, error)
+}
+
+// Returner is implemented by writers that may not write every row that
+// they're given, such as database writers that ignore rows that conflict
+// with existing rows.
+type Returner interface {
+	// WriteReturning writes a batch of rows like Write, returning the
+	// values of the given columns for each row that was written.
+	WriteReturning(ctx context.Context, table model.Table, rows [][]any, columns []string) ([][]any, error)
+}
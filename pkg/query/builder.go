@@ -12,16 +12,7 @@ import (
 func BuildInsert(table model.Table, rows [][]any, insertMode model.InsertMode) (string, error) {
 	var b model.ErrBuilder
 
-	columnNames := lo.Map(table.Columns, func(c model.Column, i int) string {
-		return c.Name
-	})
-
-	b.WriteString(
-		"%s INTO %s (%s) VALUES ",
-		lo.Ternary(insertMode == model.InsertModeInsert || insertMode == model.InsertModeConflict, "INSERT", "UPSERT"),
-		table.Name,
-		strings.Join(columnNames, ","),
-	)
+	writeInsertPrefix(&b, table, insertMode)
 
 	argIndex := 1
 	for i, row := range rows {
@@ -39,10 +30,52 @@ func BuildInsert(table model.Table, rows [][]any, insertMode model.InsertMode) (
 		argIndex += len(table.Columns)
 	}
 
-	if insertMode == model.InsertModeConflict {
-		b.WriteString(" ON CONFLICT DO NOTHING")
+	writeInsertSuffix(&b, insertMode)
+
+	if err := b.Error(); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+// BuildInsertReturning returns the same statement as BuildInsert, which
+// also returns the given columns of each inserted row as strings.
+func BuildInsertReturning(table model.Table, rows [][]any, insertMode model.InsertMode, columns []string) (string, error) {
+	stmt, err := BuildInsert(table, rows, insertMode)
+	if err != nil {
+		return "", err
+	}
+
+	returning := lo.Map(columns, func(c string, _ int) string {
+		return c + "::STRING"
+	})
+
+	return fmt.Sprintf("%s RETURNING %s", stmt, strings.Join(returning, ",")), nil
+}
+
+// BuildInsertLiteral returns the same statement as BuildInsert but with
+// values rendered as literals rather than placeholders, allowing it to be
+// run without any arguments.
+func BuildInsertLiteral(table model.Table, rows [][]any, insertMode model.InsertMode) (string, error) {
+	var b model.ErrBuilder
+
+	writeInsertPrefix(&b, table, insertMode)
+
+	for i, row := range rows {
+		literals := lo.Map(row, func(v any, _ int) string {
+			return Literal(v)
+		})
+
+		b.WriteString("(%s)", strings.Join(literals, ","))
+
+		if i < len(rows)-1 {
+			b.WriteString(",")
+		}
 	}
 
+	writeInsertSuffix(&b, insertMode)
+
 	if err := b.Error(); err != nil {
 		return "", err
 	}
@@ -50,6 +83,25 @@ func BuildInsert(table model.Table, rows [][]any, insertMode model.InsertMode) (
 	return b.String(), nil
 }
 
+func writeInsertPrefix(b *model.ErrBuilder, table model.Table, insertMode model.InsertMode) {
+	columnNames := lo.Map(table.Columns, func(c model.Column, i int) string {
+		return c.Name
+	})
+
+	b.WriteString(
+		"%s INTO %s (%s) VALUES ",
+		lo.Ternary(insertMode == model.InsertModeInsert || insertMode == model.InsertModeConflict, "INSERT", "UPSERT"),
+		table.Name,
+		strings.Join(columnNames, ","),
+	)
+}
+
+func writeInsertSuffix(b *model.ErrBuilder, insertMode model.InsertMode) {
+	if insertMode == model.InsertModeConflict {
+		b.WriteString(" ON CONFLICT DO NOTHING")
+	}
+}
+
 func valuePlaceholders(count, start int) (string, error) {
 	var b model.ErrBuilder
 
@@ -87,3 +87,130 @@ func TestBuildInsert(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildInsertReturning(t *testing.T) {
+	table := model.Table{
+		Name:    "t",
+		Columns: []model.Column{{Name: "a"}, {Name: "b"}},
+	}
+
+	actStatement, err := BuildInsertReturning(table, [][]any{{1, 2}, {3, 4}}, model.InsertModeConflict, []string{"a", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, `INSERT INTO t (a,b) VALUES ($1,$2),($3,$4) ON CONFLICT DO NOTHING RETURNING a::STRING,b::STRING`, actStatement)
+}
+
+func TestBuildInsertLiteral(t *testing.T) {
+	cases := []struct {
+		name         string
+		rows         [][]any
+		insertMode   model.InsertMode
+		expStatement string
+	}{
+		{
+			name:         "insert",
+			rows:         [][]any{{1, "a"}, {2, nil}},
+			insertMode:   model.InsertModeInsert,
+			expStatement: `INSERT INTO t (a,b) VALUES (1,'a'),(2,NULL)`,
+		},
+		{
+			name:         "conflict",
+			rows:         [][]any{{1, "a"}},
+			insertMode:   model.InsertModeConflict,
+			expStatement: `INSERT INTO t (a,b) VALUES (1,'a') ON CONFLICT DO NOTHING`,
+		},
+		{
+			name:         "upsert",
+			rows:         [][]any{{1, "a"}},
+			insertMode:   model.InsertModeUpsert,
+			expStatement: `UPSERT INTO t (a,b) VALUES (1,'a')`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			table := model.Table{
+				Name:    "t",
+				Columns: []model.Column{{Name: "a"}, {Name: "b"}},
+			}
+
+			actStatement, err := BuildInsertLiteral(table, c.rows, c.insertMode)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			assert.Equal(t, c.expStatement, actStatement)
+		})
+	}
+}
+
+func TestBuildImport(t *testing.T) {
+	table := model.Table{
+		Name:    "t",
+		Columns: []model.Column{{Name: "a"}, {Name: "b"}},
+	}
+
+	act := BuildImport(table, "http://localhost:3000/t.csv")
+	assert.Equal(t, `IMPORT INTO t (a,b) CSV DATA ('http://localhost:3000/t.csv') WITH skip = '1', nullif = ''`, act)
+}
+
+func TestBuildMax(t *testing.T) {
+	assert.Equal(t, "SELECT MAX(id)::INT8 FROM member", BuildMax("member", "id", "", ""))
+	assert.Equal(t, "SELECT MAX(substr(ref, 5, length(ref) - 7)::INT8)::INT8 FROM purchase", BuildMax("purchase", "ref", "ORD-", "-UK"))
+}
+
+func TestBuildMaxPer(t *testing.T) {
+	assert.Equal(
+		t,
+		"SELECT COALESCE(order_id::STRING, ''), MAX(line)::INT8 FROM order_line GROUP BY order_id",
+		BuildMaxPer("order_line", "line", "order_id", "", ""),
+	)
+}
+
+func TestBuildSumPer(t *testing.T) {
+	assert.Equal(t, "SELECT '', SUM(amount)::STRING FROM ledger", BuildSumPer("ledger", "amount", ""))
+	assert.Equal(
+		t,
+		"SELECT COALESCE(account_id::STRING, ''), SUM(amount)::STRING FROM ledger GROUP BY account_id",
+		BuildSumPer("ledger", "amount", "account_id"),
+	)
+}
+
+func TestBuildLastReadings(t *testing.T) {
+	cases := []struct {
+		name   string
+		per    string
+		values []string
+		exp    string
+	}{
+		{
+			name:   "single series",
+			values: []string{"level"},
+			exp:    "SELECT '', ts::TIMESTAMPTZ, level::FLOAT8 FROM reading ORDER BY ts DESC LIMIT 1",
+		},
+		{
+			name:   "series per device",
+			per:    "device_id",
+			values: []string{"level", "temp"},
+			exp:    "SELECT DISTINCT ON (device_id) COALESCE(device_id::STRING, ''), ts::TIMESTAMPTZ, level::FLOAT8, temp::FLOAT8 FROM reading ORDER BY device_id, ts DESC",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, BuildLastReadings("reading", c.per, "ts", c.values))
+		})
+	}
+}
+
+func TestBuildSelectValues(t *testing.T) {
+	assert.Equal(t, "SELECT id::STRING FROM member WHERE id IS NOT NULL LIMIT $1", BuildSelectValues("member", "id"))
+}
+
+func TestBuildRefQuery(t *testing.T) {
+	act := BuildRefQuery(" SELECT id FROM member WHERE active;\n")
+	assert.Equal(t, "SELECT v::STRING FROM (SELECT id FROM member WHERE active) AS q (v) WHERE v IS NOT NULL", act)
+}
+
+func TestBuildUpdate(t *testing.T) {
+	assert.Equal(t, "UPDATE department SET head_id = $1 WHERE id = $2", BuildUpdate("department", "head_id", "id"))
+}
@@ -0,0 +1,24 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+	"github.com/samber/lo"
+)
+
+// BuildImport returns an IMPORT INTO statement that loads a table from a
+// CSV file with a header row, in which empty values represent NULL.
+func BuildImport(table model.Table, url string) string {
+	columnNames := lo.Map(table.Columns, func(c model.Column, i int) string {
+		return c.Name
+	})
+
+	return fmt.Sprintf(
+		"IMPORT INTO %s (%s) CSV DATA (%s) WITH skip = '1', nullif = ''",
+		table.Name,
+		strings.Join(columnNames, ","),
+		quote(url),
+	)
+}
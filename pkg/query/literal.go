@@ -0,0 +1,46 @@
+package query
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/codingconcepts/dgs/pkg/model"
+)
+
+// Literal renders a generated value as a SQL literal. Numbers and booleans
+// are written as-is, while everything else is written as a quoted string
+// for the database to coerce into the column's type.
+func Literal(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		return strconv.FormatBool(x)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return model.FormatValue(x)
+	case float32:
+		return floatLiteral(float64(x), 32)
+	case float64:
+		return floatLiteral(x, 64)
+	default:
+		return quote(model.FormatValue(x))
+	}
+}
+
+func floatLiteral(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return quote("NaN")
+	case math.IsInf(f, 1):
+		return quote("Infinity")
+	case math.IsInf(f, -1):
+		return quote("-Infinity")
+	default:
+		return strconv.FormatFloat(f, 'g', -1, bitSize)
+	}
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,37 @@
+package query
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiteral(t *testing.T) {
+	cases := []struct {
+		name  string
+		value any
+		exp   string
+	}{
+		{name: "nil", value: nil, exp: "NULL"},
+		{name: "bool", value: true, exp: "true"},
+		{name: "int", value: int64(-1), exp: "-1"},
+		{name: "uint", value: uint8(1), exp: "1"},
+		{name: "float", value: 1.25, exp: "1.25"},
+		{name: "nan", value: math.NaN(), exp: "'NaN'"},
+		{name: "infinity", value: math.Inf(-1), exp: "'-Infinity'"},
+		{name: "string", value: "a", exp: "'a'"},
+		{name: "string with quote", value: "it's", exp: "'it''s'"},
+		{name: "bytes", value: []byte{0xbe, 0xef}, exp: `'\xbeef'`},
+		{name: "interval", value: time.Second * 5, exp: "'5s'"},
+		{name: "array", value: pgtype.Array[any]{Elements: []any{"o'k"}}, exp: `'{"o''k"}'`},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.exp, Literal(c.value))
+		})
+	}
+}
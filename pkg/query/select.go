@@ -0,0 +1,86 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+)
+
+// BuildMax returns a statement that selects the largest number in a column
+// as an integer, or NULL if the table is empty. The numbers in formatted
+// columns are found between the given prefix and suffix.
+func BuildMax(table, column, prefix, suffix string) string {
+	return fmt.Sprintf("SELECT MAX(%s)::INT8 FROM %s", number(column, prefix, suffix), table)
+}
+
+// BuildMaxPer returns a statement that selects each value of the per
+// column as a string (empty for NULL), and the largest number in the
+// column among the rows with that value, as an integer.
+func BuildMaxPer(table, column, per, prefix, suffix string) string {
+	return fmt.Sprintf(
+		"SELECT COALESCE(%s::STRING, ''), MAX(%s)::INT8 FROM %s GROUP BY %s",
+		per, number(column, prefix, suffix), table, per,
+	)
+}
+
+// BuildSumPer returns a statement that selects each value of the per
+// column as a string (empty for NULL, or if there's no per column), and
+// the sum of the column among the rows with that value, as a string.
+func BuildSumPer(table, column, per string) string {
+	if per == "" {
+		return fmt.Sprintf("SELECT '', SUM(%s)::STRING FROM %s", column, table)
+	}
+
+	return fmt.Sprintf("SELECT COALESCE(%s::STRING, ''), SUM(%s)::STRING FROM %s GROUP BY %s", per, column, table, per)
+}
+
+// BuildLastReadings returns a statement that selects the last reading of
+// each of a table's series: the value of the per column as a string (empty
+// for NULL, or if there's no per column), the reading's time, and each of
+// its values as a float.
+func BuildLastReadings(table, per, timestamp string, values []string) string {
+	columns := lo.Map(values, func(v string, _ int) string { return v + "::FLOAT8" })
+	selected := strings.Join(append([]string{timestamp + "::TIMESTAMPTZ"}, columns...), ", ")
+
+	if per == "" {
+		return fmt.Sprintf("SELECT '', %s FROM %s ORDER BY %s DESC LIMIT 1", selected, table, timestamp)
+	}
+
+	return fmt.Sprintf(
+		"SELECT DISTINCT ON (%s) COALESCE(%s::STRING, ''), %s FROM %s ORDER BY %s, %s DESC",
+		per, per, selected, table, per, timestamp,
+	)
+}
+
+// number returns an expression for the number in a column, between the
+// given prefix and suffix, if there are any.
+func number(column, prefix, suffix string) string {
+	if prefix == "" && suffix == "" {
+		return column
+	}
+
+	p, s := utf8.RuneCountInString(prefix), utf8.RuneCountInString(suffix)
+	return fmt.Sprintf("substr(%s, %d, length(%s) - %d)::INT8", column, p+1, column, p+s)
+}
+
+// BuildSelectValues returns a statement that selects up to $1 non-null
+// values of a column as strings, which can be written back into columns of
+// any type.
+func BuildSelectValues(table, column string) string {
+	return fmt.Sprintf("SELECT %s::STRING FROM %s WHERE %s IS NOT NULL LIMIT $1", column, table, column)
+}
+
+// BuildRefQuery wraps a query that returns a single column, so that its
+// values are returned as strings, which can be written back into columns
+// of any type.
+func BuildRefQuery(query string) string {
+	return fmt.Sprintf("SELECT v::STRING FROM (%s) AS q (v) WHERE v IS NOT NULL", strings.TrimRight(strings.TrimSpace(query), ";"))
+}
+
+// BuildUpdate returns a statement that sets a column to $1 in the row whose
+// key is $2.
+func BuildUpdate(table, column, key string) string {
+	return fmt.Sprintf("UPDATE %s SET %s = $1 WHERE %s = $2", table, column, key)
+}
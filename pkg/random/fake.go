@@ -204,6 +204,10 @@ var (
 		"${user_agent}":                  func() any { return gofakeit.UserAgent() },
 		"${username}":                    func() any { return gofakeit.Username() },
 		"${uuid}":                        func() any { return gofakeit.UUID() },
+		"${uuid_v7}":                     func() any { return UUIDv7() },
+		"${ulid}":                        func() any { return ULID() },
+		"${ksuid}":                       func() any { return KSUID() },
+		"${snowflake}":                   func() any { return Snowflake() },
 		"${vegetable}":                   func() any { return gofakeit.Vegetable() },
 		"${verb_action}":                 func() any { return gofakeit.VerbAction() },
 		"${verb_helping}":                func() any { return gofakeit.VerbHelping() },
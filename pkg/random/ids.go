@@ -0,0 +1,89 @@
+package random
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/oklog/ulid/v2"
+	"github.com/segmentio/ksuid"
+)
+
+// snowflakeEpoch is the time from which snowflake timestamps are measured
+// (2010-11-04T01:42:54.657Z, as used by Twitter).
+const snowflakeEpoch = int64(1288834974657)
+
+// rngReader reads random bytes from rng, so that IDs with random parts
+// are repeatable with Seed.
+type rngReader struct{}
+
+func (rngReader) Read(b []byte) (int, error) {
+	read(b)
+	return len(b), nil
+}
+
+// UUIDv7 returns a UUID that starts with the current time, so that UUIDs
+// sort in the order they were generated.
+func UUIDv7() string {
+	return uuid.Must(uuid.NewV7FromReader(rngReader{})).String()
+}
+
+// UUIDv5 returns a UUID derived from a namespace and a name, which is the
+// same every time it's derived from the same namespace and name.
+func UUIDv5(namespace uuid.UUID, name string) string {
+	return uuid.NewSHA1(namespace, []byte(name)).String()
+}
+
+// ULID returns a lexicographically sortable identifier that starts with
+// the current time.
+func ULID() string {
+	return ulid.MustNew(ulid.Timestamp(time.Now()), rngReader{}).String()
+}
+
+// KSUID returns a K-sortable identifier that starts with the current
+// time.
+func KSUID() string {
+	payload := make([]byte, 16)
+	read(payload)
+
+	id, err := ksuid.FromParts(time.Now(), payload)
+	if err != nil {
+		// FromParts only fails for payloads of the wrong size.
+		panic(err)
+	}
+
+	return id.String()
+}
+
+// snowflake holds the state of snowflake IDs, which are never repeated
+// within a run.
+var snowflake struct {
+	sync.Mutex
+	last     int64
+	sequence int64
+}
+
+// Snowflake returns a 64-bit integer made up of the milliseconds since
+// the snowflake epoch, a machine ID (always 0), and a sequence that
+// distinguishes IDs generated in the same millisecond. If the sequence
+// runs out, IDs continue from the next millisecond, so IDs always
+// increase.
+func Snowflake() int64 {
+	snowflake.Lock()
+	defer snowflake.Unlock()
+
+	now := time.Now().UnixMilli() - snowflakeEpoch
+
+	switch {
+	case now > snowflake.last:
+		snowflake.last = now
+		snowflake.sequence = 0
+	case snowflake.sequence == 1<<12-1:
+		snowflake.last++
+		snowflake.sequence = 0
+	default:
+		snowflake.sequence++
+	}
+
+	return snowflake.last<<22 | snowflake.sequence
+}
@@ -0,0 +1,67 @@
+package random
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortableIDs(t *testing.T) {
+	cases := []struct {
+		name       string
+		gen        func() string
+		length     int
+		resolution time.Duration
+	}{
+		{name: "uuid v7", gen: UUIDv7, length: 36, resolution: time.Millisecond},
+		{name: "ulid", gen: ULID, length: 26, resolution: time.Millisecond},
+		{name: "ksuid", gen: KSUID, length: 27, resolution: time.Second},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			first := c.gen()
+			assert.Len(t, first, c.length)
+
+			// IDs generated at a later time sort after earlier ones.
+			time.Sleep(c.resolution)
+			second := c.gen()
+
+			assert.Less(t, first, second)
+		})
+	}
+}
+
+func TestUUIDv7(t *testing.T) {
+	id, err := uuid.Parse(UUIDv7())
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), id.Version())
+}
+
+func TestUUIDv5(t *testing.T) {
+	a := UUIDv5(uuid.NameSpaceURL, "a")
+
+	id, err := uuid.Parse(a)
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Version(5), id.Version())
+
+	assert.Equal(t, a, UUIDv5(uuid.NameSpaceURL, "a"))
+	assert.NotEqual(t, a, UUIDv5(uuid.NameSpaceURL, "b"))
+	assert.NotEqual(t, a, UUIDv5(uuid.NameSpaceDNS, "a"))
+}
+
+func TestSnowflake(t *testing.T) {
+	ids := make([]int64, 10000)
+	for i := range ids {
+		ids[i] = Snowflake()
+	}
+
+	assert.True(t, sort.SliceIsSorted(ids, func(i, j int) bool { return ids[i] < ids[j] }))
+
+	for i := 1; i < len(ids); i++ {
+		assert.NotEqual(t, ids[i-1], ids[i])
+	}
+}
@@ -0,0 +1,71 @@
+package random
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// rng is the source of every random value that dgs generates, allowing
+// generation to be made repeatable with Seed. As rand.Rand isn't safe for
+// concurrent use, access is guarded by rngMu.
+var (
+	rngMu sync.Mutex
+	rng   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// Seed reseeds the random sources used to generate values, including the
+// sources used by gofakeit.
+func Seed(seed int64) {
+	rngMu.Lock()
+	rng = rand.New(rand.NewSource(seed))
+	rngMu.Unlock()
+
+	gofakeit.Seed(seed)
+}
+
+// Sample returns a random item from a collection, or the zero value if the
+// collection is empty.
+func Sample[T any](collection []T) T {
+	if len(collection) == 0 {
+		var zero T
+		return zero
+	}
+
+	return collection[intn(len(collection))]
+}
+
+func int63n(n int64) int64 {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.Int63n(n)
+}
+
+func intn(n int) int {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.Intn(n)
+}
+
+func float64n() float64 {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	return rng.Float64()
+}
+
+func read(b []byte) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng.Read(b)
+}
+
+// Shuffle randomly reorders a collection in place.
+func Shuffle[T any](collection []T) {
+	rngMu.Lock()
+	defer rngMu.Unlock()
+	rng.Shuffle(len(collection), func(i, j int) {
+		collection[i], collection[j] = collection[j], collection[i]
+	})
+}
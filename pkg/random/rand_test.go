@@ -0,0 +1,38 @@
+package random
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeed(t *testing.T) {
+	generate := func() []any {
+		return []any{
+			Int(0, 1000000),
+			Float(0, 1),
+			String(10, 20),
+			Sample([]string{"a", "b", "c", "d", "e"}),
+			Replacements["${first_name}"](),
+		}
+	}
+
+	Seed(42)
+	first := generate()
+
+	Seed(42)
+	second := generate()
+
+	assert.Equal(t, first, second)
+}
+
+func TestSample_Empty(t *testing.T) {
+	assert.Equal(t, "", Sample([]string{}))
+}
+
+func TestShuffle(t *testing.T) {
+	values := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	Shuffle(values)
+
+	assert.ElementsMatch(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, values)
+}
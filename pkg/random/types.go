@@ -1,10 +1,7 @@
 package random
 
 import (
-	crand "crypto/rand"
-	"fmt"
 	"math"
-	"math/rand"
 	"time"
 
 	"github.com/google/uuid"
@@ -45,7 +42,7 @@ func Int(min, max int64) int64 {
 		min, max = max, min
 	}
 
-	return rand.Int63n(max-min) + min
+	return int63n(max-min) + min
 }
 
 func Float(min, max float64) float64 {
@@ -57,7 +54,7 @@ func Float(min, max float64) float64 {
 		min, max = max, min
 	}
 
-	return min + rand.Float64()*(max-min)
+	return min + float64n()*(max-min)
 }
 
 func Timestamp(min, max time.Time) time.Time {
@@ -73,7 +70,7 @@ func Timestamp(min, max time.Time) time.Time {
 	maxUnix := max.Unix()
 	delta := maxUnix - minUnix
 
-	randUnix := minUnix + rand.Int63n(delta)
+	randUnix := minUnix + int63n(delta)
 	return time.Unix(randUnix, 0)
 }
 
@@ -87,14 +84,14 @@ func Interval(min, max time.Duration) time.Duration {
 	}
 
 	diff := max - min
-	randomDiff := time.Duration(rand.Int63n(int64(diff)))
+	randomDiff := time.Duration(int63n(int64(diff)))
 
 	return min + randomDiff
 }
 
 func Point(lat, lon, radiusMiles float64) (float64, float64) {
-	randomDistance := (rand.Float64() * radiusMiles) / earthRadiusMiles
-	randomBearing := rand.Float64() * 2 * math.Pi
+	randomDistance := (float64n() * radiusMiles) / earthRadiusMiles
+	randomBearing := float64n() * 2 * math.Pi
 
 	latRad := degreesToRadians(lat)
 	lonRad := degreesToRadians(lon)
@@ -128,10 +125,7 @@ func Bytes(min, max int64) ([]byte, error) {
 	n := Int(min, max)
 	result := make([]byte, n)
 
-	_, err := crand.Read(result)
-	if err != nil {
-		return nil, fmt.Errorf("creating random bytes: %w", err)
-	}
+	read(result)
 
 	return result, nil
 }
@@ -141,7 +135,7 @@ func String(min, max int64) string {
 	result := make([]rune, size)
 
 	for i := 0; i < int(size); i++ {
-		result[i] = rune(ascii[rand.Intn(asciiLen)])
+		result[i] = rune(ascii[intn(asciiLen)])
 	}
 
 	return string(result)